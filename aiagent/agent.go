@@ -2,25 +2,49 @@ package aiagent
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"slices"
 	"strings"
 )
 
-const (
-	toolCallLimit = 10
-)
-
 type LLM interface {
 	Call(ctx context.Context, history []Message) (Message, error)
 	RegisterTool(tool Tool)
 }
 
+// ModelNamer is implemented by LLMs that know the model they call.
+// LLM wrappers implement it by asking the LLM they wrap, see ModelName.
+type ModelNamer interface {
+	ModelName() string
+}
+
+// ModelName returns the model llm calls, or "" if it does not tell.
+func ModelName(llm LLM) string {
+	if n, ok := llm.(ModelNamer); ok {
+		return n.ModelName()
+	}
+	return ""
+}
+
 type Agent struct {
 	llm          LLM
 	toolRegistry map[string]Tool
 
+	// toolConcurrency limits how many tool calls of one llm response run at once.
+	// Zero or negative means no limit.
+	toolConcurrency int
+	toolErrorPolicy ToolErrorPolicy
+
+	unknownToolLimit int
+	unknownToolHooks []func(ctx context.Context, req ToolCallRequest)
+
+	budget     Budget
+	generation GenerationConfig
+
+	interceptors []Interceptor
+
+	historyPolicy HistoryPolicy
+	compactor     Compactor
+
 	debug bool
 }
 
@@ -28,9 +52,12 @@ type AgentOption func(*Agent)
 
 func NewAgent(llm LLM, opts ...AgentOption) *Agent {
 	agent := &Agent{
-		llm:          llm,
-		toolRegistry: make(map[string]Tool),
-		debug:        false,
+		llm:              llm,
+		toolRegistry:     make(map[string]Tool),
+		toolConcurrency:  1,
+		unknownToolLimit: defaultUnknownToolLimit,
+		budget:           Budget{MaxIterations: defaultMaxIterations},
+		debug:            false,
 	}
 	for _, opt := range opts {
 		opt(agent)
@@ -52,6 +79,14 @@ func WithDebug() AgentOption {
 	}
 }
 
+// WithToolConcurrency allows up to n tool calls requested in a single llm
+// response to be executed concurrently. n <= 0 removes the limit.
+func WithToolConcurrency(n int) AgentOption {
+	return func(a *Agent) {
+		a.toolConcurrency = n
+	}
+}
+
 func WithTools(tools ...Tool) AgentOption {
 	return func(a *Agent) {
 		for _, t := range tools {
@@ -65,6 +100,14 @@ type SendOption func(*sendOpts)
 
 type sendOpts struct {
 	appendSystemPrompt []string
+	budget             Budget
+	onDelta            func(Delta)
+	callConfig         CallConfig
+	toolChoice         ToolChoice
+	typedRetries       int
+	// checkAnswer is called with every final answer. It accepts the answer
+	// or returns a message that asks the llm to answer again.
+	checkAnswer func(resp Message) (Message, bool, error)
 }
 
 func WithSystemPromptAppend(p string) SendOption {
@@ -73,61 +116,39 @@ func WithSystemPromptAppend(p string) SendOption {
 	}
 }
 
-func (a *Agent) SendMessage(ctx context.Context, userMessage string, opts ...SendOption) (string, error) {
-	var so sendOpts
-	for _, f := range opts {
-		f(&so)
-	}
+func (a *Agent) SendMessage(ctx context.Context, userMessage string, opts ...SendOption) (RunResult, error) {
+	so := newSendOpts(opts)
 
 	systemPrompt := a.newSystemPrompt(so.appendSystemPrompt)
 	history := a.initialHistory(userMessage, systemPrompt)
 
-	return a.Send(ctx, history)
+	return a.run(ctx, newRunState(history), so, nil)
 }
 
-func (a *Agent) Send(ctx context.Context, chat []Message) (string, error) {
-	history := slices.Clone(chat)
-
-	if a.debug {
-		defer func() {
-			a.printHistory(history)
-			fmt.Println()
-		}()
-	}
-
-	for range toolCallLimit {
-		resp, err := a.llm.Call(ctx, history)
-		if err != nil {
-			return "", fmt.Errorf("call llm: %w", err)
-		}
-		history = append(history, resp)
-		if resp.Type() == MessageTypeAssistant {
-			return resp.MustText(), nil
-		}
-
-		// llm resp msgtype != MessageTypeAssistant => msgtype == MessageTypeToolCallRequest
-		for _, tcReq := range resp.MustToolCallRequests() {
-			tcResponse, errExec := a.executeTool(ctx, tcReq)
-			if errExec != nil {
-				return "", errExec
-			}
-			history = append(history, tcResponse)
-		}
-	}
-
-	return "", errors.New("max tool call limit exceeded")
+func (a *Agent) Send(ctx context.Context, chat []Message, opts ...SendOption) (RunResult, error) {
+	return a.run(ctx, newRunState(chat), newSendOpts(opts), nil)
 }
 
-func (a *Agent) executeTool(ctx context.Context, req ToolCallRequest) (Message, error) {
-	tcExecutable := a.toolRegistry[req.Call.Name]
+// Stream runs the same loop as Send, reporting text and tool call deltas
+// of every llm response to onDelta as they arrive.
+func (a *Agent) Stream(
+	ctx context.Context,
+	chat []Message,
+	onDelta func(Delta),
+	opts ...SendOption,
+) (RunResult, error) {
+	so := newSendOpts(opts)
+	so.onDelta = onDelta
+
+	return a.run(ctx, newRunState(chat), so, nil)
+}
 
-	var content string
-	content, err := tcExecutable.Execute(ctx, req.Args)
-	if err != nil {
-		return Message{}, fmt.Errorf("call tool %s: %w", req.Call.Name, err)
+func newSendOpts(opts []SendOption) sendOpts {
+	so := sendOpts{typedRetries: defaultTypedRetries}
+	for _, f := range opts {
+		f(&so)
 	}
-
-	return NewToolCallResponseMessage(req.Call.ID, req.Call.Name, content), nil
+	return so
 }
 
 func (a *Agent) initialHistory(userMessage string, sysPromt string) []Message {
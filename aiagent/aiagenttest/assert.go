@@ -0,0 +1,64 @@
+package aiagenttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// ToolCalls returns the tool calls of a run in the order they were requested.
+func ToolCalls(res aiagent.RunResult) []aiagent.ToolCallRecord {
+	var calls []aiagent.ToolCallRecord
+	for _, s := range res.Steps {
+		calls = append(calls, s.ToolCalls...)
+	}
+	return calls
+}
+
+// AssertToolSequence checks the names of the tool calls a run performed.
+func AssertToolSequence(t testing.TB, res aiagent.RunResult, names ...string) {
+	t.Helper()
+
+	calls := ToolCalls(res)
+	got := make([]string, 0, len(calls))
+	for _, c := range calls {
+		got = append(got, c.Request.Call.Name)
+	}
+
+	if !slices.Equal(got, names) {
+		t.Errorf("aiagenttest: tool calls %q, want %q", got, names)
+	}
+}
+
+// AssertToolCall checks the name and arguments of a tool call. Arguments
+// are compared as JSON values, args being marshaled first.
+func AssertToolCall(t testing.TB, rec aiagent.ToolCallRecord, name string, args any) {
+	t.Helper()
+
+	if rec.Request.Call.Name != name {
+		t.Errorf("aiagenttest: tool call %s, want %s", rec.Request.Call.Name, name)
+		return
+	}
+
+	want, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("aiagenttest: marshal want args: %v", err)
+	}
+	if !equalJSON(rec.Request.Args, want) {
+		t.Errorf("aiagenttest: tool call %s args %s, want %s", name, rec.Request.Args, want)
+	}
+}
+
+func equalJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	ca, errA := json.Marshal(va)
+	cb, errB := json.Marshal(vb)
+	return errA == nil && errB == nil && bytes.Equal(ca, cb)
+}
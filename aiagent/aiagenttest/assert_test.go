@@ -0,0 +1,76 @@
+package aiagenttest_test
+
+import (
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+type stepArgs struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func record(name string, args string) aiagent.ToolCallRecord {
+	return aiagent.ToolCallRecord{Request: aiagent.ToolCallRequest{
+		Call: aiagent.ToolCall{Name: name},
+		Args: []byte(args),
+	}}
+}
+
+func result() aiagent.RunResult {
+	return aiagent.RunResult{Steps: []aiagent.Step{
+		{ToolCalls: []aiagent.ToolCallRecord{record("a", `{}`), record("b", `{}`)}},
+		{},
+		{ToolCalls: []aiagent.ToolCallRecord{record("a", `{}`)}},
+	}}
+}
+
+func TestToolCalls(t *testing.T) {
+	calls := aiagenttest.ToolCalls(result())
+	if len(calls) != 3 || calls[1].Request.Call.Name != "b" {
+		t.Errorf("tool calls = %+v, want a, b, a", calls)
+	}
+}
+
+func TestAssertToolSequence(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		fail  bool
+	}{
+		{name: "same", names: []string{"a", "b", "a"}},
+		{name: "other order", names: []string{"a", "a", "b"}, fail: true},
+		{name: "missing", names: []string{"a", "b"}, fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingT{TB: t}
+			aiagenttest.AssertToolSequence(rec, result(), tt.names...)
+			rec.assertFailed(t, tt.fail)
+		})
+	}
+}
+
+func TestAssertToolCall(t *testing.T) {
+	call := record("step", `{ "count": 2, "name": "first" }`)
+	tests := []struct {
+		name     string
+		toolName string
+		args     any
+		fail     bool
+	}{
+		{name: "same json", toolName: "step", args: stepArgs{Name: "first", Count: 2}},
+		{name: "map args", toolName: "step", args: map[string]any{"name": "first", "count": 2}},
+		{name: "other name", toolName: "other", args: stepArgs{Name: "first", Count: 2}, fail: true},
+		{name: "other args", toolName: "step", args: stepArgs{Name: "first", Count: 3}, fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingT{TB: t}
+			aiagenttest.AssertToolCall(rec, call, tt.toolName, tt.args)
+			rec.assertFailed(t, tt.fail)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package aiagenttest provides a scriptable fake LLM and assertions
+// for testing agents built with aiagent.
+package aiagenttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+var ErrUnexpectedCall = errors.New("unexpected llm call")
+
+// Turn is one scripted llm response. Expect is checked against the history
+// of the call the turn answers.
+type Turn struct {
+	Response aiagent.Message
+	Err      error
+	Expect   []Matcher
+}
+
+// Reply answers with a final assistant message.
+func Reply(text string) Turn {
+	return Turn{Response: aiagent.NewAssistantMessage(text)}
+}
+
+// CallTool answers with a request of a single tool call.
+// args is marshaled to JSON.
+func CallTool(name string, args any) Turn {
+	return CallTools(ToolCall(name, args))
+}
+
+// CallTools answers with a request of several tool calls.
+// Calls without an ID get a unique one when the turn is served.
+func CallTools(reqs ...aiagent.ToolCallRequest) Turn {
+	return Turn{Response: aiagent.NewToolCallRequestMessage(reqs)}
+}
+
+// Fail answers with err.
+func Fail(err error) Turn {
+	return Turn{Err: err}
+}
+
+// ToolCall builds a tool call request with args marshaled to JSON.
+// It panics if args cannot be marshaled.
+func ToolCall(name string, args any) aiagent.ToolCallRequest {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		panic(fmt.Sprintf("aiagenttest: marshal args of %s: %v", name, err))
+	}
+
+	return aiagent.ToolCallRequest{
+		Call: aiagent.ToolCall{Name: name},
+		Args: raw,
+	}
+}
+
+// Expecting returns a copy of the turn that also checks the given matchers.
+func (t Turn) Expecting(matchers ...Matcher) Turn {
+	t.Expect = append(slices.Clone(t.Expect), matchers...)
+	return t
+}
+
+// FakeLLM answers calls with scripted turns, in order. A call that does not
+// satisfy its turn's matchers, or comes after the script is used up, fails
+// the test and returns an error to the agent.
+type FakeLLM struct {
+	t testing.TB
+
+	mu     sync.Mutex
+	script []Turn
+	next   int
+	calls  [][]aiagent.Message
+	tools  []aiagent.Tool
+}
+
+func NewFakeLLM(t testing.TB, turns ...Turn) *FakeLLM {
+	return &FakeLLM{t: t, script: turns}
+}
+
+// Push appends turns to the script.
+func (f *FakeLLM) Push(turns ...Turn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.script = append(f.script, turns...)
+}
+
+func (f *FakeLLM) Call(_ context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	f.t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(f.calls)
+	f.calls = append(f.calls, slices.Clone(history))
+
+	if f.next >= len(f.script) {
+		f.t.Errorf("aiagenttest: llm call %d: script has only %d turns", n, len(f.script))
+		return aiagent.Message{}, fmt.Errorf("%w: call %d", ErrUnexpectedCall, n)
+	}
+	turn := f.script[f.next]
+	f.next++
+
+	for _, m := range turn.Expect {
+		if err := m(history); err != nil {
+			f.t.Errorf("aiagenttest: llm call %d: %v", n, err)
+			return aiagent.Message{}, fmt.Errorf("%w: call %d: %w", ErrUnexpectedCall, n, err)
+		}
+	}
+	if turn.Err != nil {
+		return aiagent.Message{}, turn.Err
+	}
+
+	return withCallIDs(turn.Response, n), nil
+}
+
+func (f *FakeLLM) RegisterTool(tool aiagent.Tool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tools = append(f.tools, tool)
+}
+
+// Calls returns the history received by every call so far.
+func (f *FakeLLM) Calls() [][]aiagent.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.calls)
+}
+
+// Tools returns the registered tools in registration order.
+func (f *FakeLLM) Tools() []aiagent.Tool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.tools)
+}
+
+// AssertTools checks that exactly the named tools were registered, in any order.
+func (f *FakeLLM) AssertTools(names ...string) {
+	f.t.Helper()
+
+	tools := f.Tools()
+	got := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		got = append(got, tool.Name())
+	}
+	want := slices.Clone(names)
+	slices.Sort(got)
+	slices.Sort(want)
+
+	if !slices.Equal(got, want) {
+		f.t.Errorf("aiagenttest: registered tools %q, want %q", got, want)
+	}
+}
+
+// AssertDone checks that every scripted turn was used.
+func (f *FakeLLM) AssertDone() {
+	f.t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.next < len(f.script) {
+		f.t.Errorf("aiagenttest: %d of %d scripted turns were not used", len(f.script)-f.next, len(f.script))
+	}
+}
+
+// withCallIDs fills in missing tool call IDs with ones unique to the call.
+func withCallIDs(resp aiagent.Message, call int) aiagent.Message {
+	if !resp.IsToolCallRequest() {
+		return resp
+	}
+
+	reqs := slices.Clone(resp.MustToolCallRequests())
+	for i := range reqs {
+		if reqs[i].Call.ID == "" {
+			reqs[i].Call.ID = fmt.Sprintf("call_%d_%d", call, i)
+		}
+	}
+	msg := aiagent.NewToolCallRequestMessage(reqs)
+	if text, err := resp.Text(); err == nil {
+		msg = aiagent.NewToolCallRequestMessageWithText(text, reqs)
+	}
+	msg = msg.WithUsage(resp.Usage())
+	if model, ok := resp.Meta(aiagent.MetaModel); ok {
+		msg = msg.WithMeta(aiagent.MetaModel, model)
+	}
+	return msg
+}
@@ -0,0 +1,194 @@
+package aiagenttest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+// recordingT collects the failures reported by the helpers under test
+// instead of failing the test running them.
+type recordingT struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) assertFailed(t *testing.T, want bool) {
+	t.Helper()
+
+	if failed := len(r.errs) > 0; failed != want {
+		t.Errorf("failed = %t with %q, want %t", failed, r.errs, want)
+	}
+}
+
+func history(texts ...string) []aiagent.Message {
+	msgs := make([]aiagent.Message, 0, len(texts))
+	for _, text := range texts {
+		msgs = append(msgs, aiagent.NewUserMessage(text))
+	}
+	return msgs
+}
+
+func TestFakeLLMServesScriptInOrder(t *testing.T) {
+	errCall := errors.New("boom")
+	rec := &recordingT{TB: t}
+	llm := aiagenttest.NewFakeLLM(rec,
+		aiagenttest.Reply("hello"),
+		aiagenttest.CallTools(
+			aiagenttest.ToolCall("step", map[string]string{"name": "first"}),
+			aiagent.ToolCallRequest{Call: aiagent.ToolCall{ID: "own", Name: "step"}, Args: []byte(`{}`)},
+		),
+		aiagenttest.Fail(errCall),
+	)
+	ctx := context.Background()
+
+	resp, err := llm.Call(ctx, history("hi"))
+	if err != nil || resp.MustText() != "hello" {
+		t.Errorf("call 0 = %v, %v, want hello", resp, err)
+	}
+
+	resp, err = llm.Call(ctx, history("hi", "again"))
+	if err != nil {
+		t.Fatalf("call 1: %v", err)
+	}
+	reqs := resp.MustToolCallRequests()
+	if len(reqs) != 2 || reqs[0].Call.ID != "call_1_0" || reqs[1].Call.ID != "own" {
+		t.Errorf("tool calls = %+v, want a generated and the scripted ID", reqs)
+	}
+	if string(reqs[0].Args) != `{"name":"first"}` {
+		t.Errorf("args = %s, want the marshaled args", reqs[0].Args)
+	}
+
+	if _, err = llm.Call(ctx, history("hi")); !errors.Is(err, errCall) {
+		t.Errorf("call 2: err = %v, want %v", err, errCall)
+	}
+
+	llm.AssertDone()
+	rec.assertFailed(t, false)
+	if calls := llm.Calls(); len(calls) != 3 || len(calls[1]) != 2 {
+		t.Errorf("calls = %v, want the three histories", calls)
+	}
+}
+
+func TestFakeLLMKeepsResponseDetails(t *testing.T) {
+	req := aiagent.NewToolCallRequestMessageWithText("let me check",
+		[]aiagent.ToolCallRequest{aiagenttest.ToolCall("step", struct{}{})})
+	req = req.WithUsage(aiagent.Usage{TotalTokens: 7})
+	req = req.WithMeta(aiagent.MetaModel, "gpt-4o")
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Turn{Response: req})
+
+	resp, err := llm.Call(context.Background(), history("hi"))
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if text, _ := resp.Text(); text != "let me check" {
+		t.Errorf("text = %q, want the scripted text", text)
+	}
+	if resp.Usage().TotalTokens != 7 {
+		t.Errorf("usage = %+v, want the scripted usage", resp.Usage())
+	}
+	if model, _ := resp.Meta(aiagent.MetaModel); model != "gpt-4o" {
+		t.Errorf("model = %q, want gpt-4o", model)
+	}
+}
+
+func TestFakeLLMUnexpectedCall(t *testing.T) {
+	rec := &recordingT{TB: t}
+	llm := aiagenttest.NewFakeLLM(rec)
+
+	if _, err := llm.Call(context.Background(), history("hi")); !errors.Is(err, aiagenttest.ErrUnexpectedCall) {
+		t.Errorf("err = %v, want %v", err, aiagenttest.ErrUnexpectedCall)
+	}
+	rec.assertFailed(t, true)
+}
+
+func TestFakeLLMExpectations(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []aiagent.Message
+		fail    bool
+	}{
+		{name: "met", history: history("a", "b")},
+		{name: "unmet", history: history("a"), fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingT{TB: t}
+			llm := aiagenttest.NewFakeLLM(rec, aiagenttest.Reply("ok").Expecting(aiagenttest.HistoryLen(2)))
+
+			_, err := llm.Call(context.Background(), tt.history)
+			if got := errors.Is(err, aiagenttest.ErrUnexpectedCall); got != tt.fail {
+				t.Errorf("err = %v, want unexpected call %t", err, tt.fail)
+			}
+			rec.assertFailed(t, tt.fail)
+		})
+	}
+}
+
+func TestTurnExpectingCopies(t *testing.T) {
+	base := aiagenttest.Reply("ok").Expecting(aiagenttest.HistoryLen(1))
+	first := base.Expecting(aiagenttest.LastUserText("first"))
+	second := base.Expecting(aiagenttest.LastUserText("second"))
+
+	if len(base.Expect) != 1 || len(first.Expect) != 2 || len(second.Expect) != 2 {
+		t.Fatalf("matchers = %d, %d, %d, want 1, 2, 2", len(base.Expect), len(first.Expect), len(second.Expect))
+	}
+	if first.Expect[1](history("first")) != nil || second.Expect[1](history("second")) != nil {
+		t.Error("derived turns share their matchers")
+	}
+}
+
+func TestFakeLLMAssertDone(t *testing.T) {
+	rec := &recordingT{TB: t}
+	llm := aiagenttest.NewFakeLLM(rec, aiagenttest.Reply("one"))
+	llm.Push(aiagenttest.Reply("two"))
+
+	if _, err := llm.Call(context.Background(), history("hi")); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	llm.AssertDone()
+	rec.assertFailed(t, true)
+
+	rec.errs = nil
+	if resp, err := llm.Call(context.Background(), history("hi")); err != nil || resp.MustText() != "two" {
+		t.Fatalf("pushed turn = %v, %v, want two", resp, err)
+	}
+	llm.AssertDone()
+	rec.assertFailed(t, false)
+}
+
+func TestFakeLLMTools(t *testing.T) {
+	noop := func(context.Context, struct{}) (string, error) { return "", nil }
+	rec := &recordingT{TB: t}
+	llm := aiagenttest.NewFakeLLM(rec)
+	llm.RegisterTool(aiagent.MustNewDerivedTool("b", "second", noop))
+	llm.RegisterTool(aiagent.MustNewDerivedTool("a", "first", noop))
+
+	names := make([]string, 0, 2)
+	for _, tool := range llm.Tools() {
+		names = append(names, tool.Name())
+	}
+	if !slices.Equal(names, []string{"b", "a"}) {
+		t.Errorf("tools = %q, want registration order", names)
+	}
+
+	llm.AssertTools("a", "b")
+	rec.assertFailed(t, false)
+	llm.AssertTools("a")
+	rec.assertFailed(t, true)
+}
@@ -0,0 +1,67 @@
+package aiagenttest
+
+import (
+	"fmt"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// Matcher checks the history an llm call receives.
+type Matcher func(history []aiagent.Message) error
+
+// HistoryLen matches a history of n messages.
+func HistoryLen(n int) Matcher {
+	return func(history []aiagent.Message) error {
+		if len(history) != n {
+			return fmt.Errorf("history has %d messages, want %d", len(history), n)
+		}
+		return nil
+	}
+}
+
+// LastUserText matches a history whose latest user message is text.
+func LastUserText(text string) Matcher {
+	return func(history []aiagent.Message) error {
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Type() != aiagent.MessageTypeUser {
+				continue
+			}
+			if got := history[i].MustText(); got != text {
+				return fmt.Errorf("last user message is %q, want %q", got, text)
+			}
+			return nil
+		}
+		return fmt.Errorf("no user message, want %q", text)
+	}
+}
+
+// SystemPrompt matches a history starting with the system prompt text.
+func SystemPrompt(text string) Matcher {
+	return func(history []aiagent.Message) error {
+		if len(history) == 0 || history[0].Type() != aiagent.MessageTypeSystem {
+			return fmt.Errorf("no system prompt, want %q", text)
+		}
+		if got := history[0].MustText(); got != text {
+			return fmt.Errorf("system prompt is %q, want %q", got, text)
+		}
+		return nil
+	}
+}
+
+// ToolResult matches a history whose trailing tool responses include
+// a response of the named tool with the given result.
+func ToolResult(name string, result string) Matcher {
+	return func(history []aiagent.Message) error {
+		for i := len(history) - 1; i >= 0 && history[i].IsToolCallResponse(); i-- {
+			resp := history[i].MustToolCallResponse()
+			if resp.Call.Name != name {
+				continue
+			}
+			if resp.Result != result {
+				return fmt.Errorf("tool %s returned %q, want %q", name, resp.Result, result)
+			}
+			return nil
+		}
+		return fmt.Errorf("no response of tool %s at the end of history", name)
+	}
+}
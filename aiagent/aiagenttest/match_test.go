@@ -0,0 +1,51 @@
+package aiagenttest_test
+
+import (
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func TestMatchers(t *testing.T) {
+	toolTurn := []aiagent.Message{
+		aiagent.NewSystemMessage("be brief"),
+		aiagent.NewUserMessage("first"),
+		aiagent.NewAssistantMessage("done"),
+		aiagent.NewUserMessage("second"),
+		aiagent.NewToolCallRequestMessage([]aiagent.ToolCallRequest{
+			aiagenttest.ToolCall("a", struct{}{}),
+			aiagenttest.ToolCall("b", struct{}{}),
+		}),
+		aiagent.NewToolCallResponseMessage("1", "a", "a done"),
+		aiagent.NewToolCallResponseMessage("2", "b", "b done"),
+	}
+	answered := append(toolTurn[:len(toolTurn):len(toolTurn)], aiagent.NewAssistantMessage("all done"))
+
+	tests := []struct {
+		name    string
+		matcher aiagenttest.Matcher
+		history []aiagent.Message
+		ok      bool
+	}{
+		{name: "history len", matcher: aiagenttest.HistoryLen(7), history: toolTurn, ok: true},
+		{name: "history len differs", matcher: aiagenttest.HistoryLen(6), history: toolTurn},
+		{name: "last user text", matcher: aiagenttest.LastUserText("second"), history: toolTurn, ok: true},
+		{name: "earlier user text", matcher: aiagenttest.LastUserText("first"), history: toolTurn},
+		{name: "no user text", matcher: aiagenttest.LastUserText("first"), history: toolTurn[:1]},
+		{name: "system prompt", matcher: aiagenttest.SystemPrompt("be brief"), history: toolTurn, ok: true},
+		{name: "system prompt differs", matcher: aiagenttest.SystemPrompt("be long"), history: toolTurn},
+		{name: "no system prompt", matcher: aiagenttest.SystemPrompt("be brief"), history: toolTurn[1:]},
+		{name: "tool result", matcher: aiagenttest.ToolResult("a", "a done"), history: toolTurn, ok: true},
+		{name: "tool result differs", matcher: aiagenttest.ToolResult("b", "failed"), history: toolTurn},
+		{name: "no tool result", matcher: aiagenttest.ToolResult("c", "c done"), history: toolTurn},
+		{name: "tool result not trailing", matcher: aiagenttest.ToolResult("a", "a done"), history: answered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher(tt.history); (err == nil) != tt.ok {
+				t.Errorf("err = %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package aiagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidApproval = errors.New("invalid approval")
+	// ErrPendingApproval is returned by SendTyped when the run stopped for
+	// approval, and by Session.Send while a run of the session is suspended.
+	ErrPendingApproval = errors.New("run is pending approval")
+)
+
+// ApprovalRequirer is implemented by tools whose calls have to be approved
+// before they are executed, see RequireApproval.
+type ApprovalRequirer interface {
+	RequiresApproval() bool
+}
+
+// RequireApproval wraps t so that the run suspends with StopReasonPendingApproval
+// whenever the llm calls it.
+func RequireApproval(t Tool) Tool {
+	return approvalTool{Tool: t}
+}
+
+type approvalTool struct {
+	Tool
+}
+
+func (approvalTool) RequiresApproval() bool { return true }
+
+func requiresApproval(t Tool) bool {
+	r, ok := t.(ApprovalRequirer)
+	return ok && r.RequiresApproval()
+}
+
+// PendingApproval describes a run suspended before executing a tool request.
+// It is plain data and can be stored as JSON until the decisions are made.
+type PendingApproval struct {
+	Calls []PendingCall `json:"calls"`
+	// Iterations and ToolCalls carry the budget consumed before suspension.
+	Iterations int `json:"iterations"`
+	ToolCalls  int `json:"tool_calls"`
+}
+
+type PendingCall struct {
+	Request       ToolCallRequest `json:"request"`
+	NeedsApproval bool            `json:"needs_approval"`
+}
+
+type ApprovalVerdict uint8
+
+const (
+	ApprovalApprove ApprovalVerdict = iota
+	ApprovalDeny
+	ApprovalEdit
+)
+
+type ApprovalDecision struct {
+	CallID  string
+	Verdict ApprovalVerdict
+	// Args replaces the call arguments for ApprovalEdit.
+	Args json.RawMessage
+	// Reason is sent to the llm for ApprovalDeny.
+	Reason string
+}
+
+func Approve(callID string) ApprovalDecision {
+	return ApprovalDecision{CallID: callID, Verdict: ApprovalApprove}
+}
+
+func Deny(callID string, reason string) ApprovalDecision {
+	return ApprovalDecision{CallID: callID, Verdict: ApprovalDeny, Reason: reason}
+}
+
+func EditArgs(callID string, args json.RawMessage) ApprovalDecision {
+	return ApprovalDecision{CallID: callID, Verdict: ApprovalEdit, Args: args}
+}
+
+// Resume continues a run suspended with StopReasonPendingApproval. history is
+// the History of the suspended RunResult, which ends with the tool request
+// awaiting approval. Every call that needs approval must have a decision;
+// the other calls of the request are executed as usual.
+func (a *Agent) Resume(
+	ctx context.Context,
+	history []Message,
+	pending PendingApproval,
+	decisions []ApprovalDecision,
+	opts ...SendOption,
+) (RunResult, error) {
+	if len(history) == 0 || !history[len(history)-1].IsToolCallRequest() {
+		return RunResult{}, fmt.Errorf("%w: history does not end with a tool request", ErrInvalidApproval)
+	}
+	last := history[len(history)-1]
+
+	byID, err := a.matchDecisions(last.MustToolCallRequests(), pending, decisions)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	st := newRunState(history[:len(history)-1])
+	st.iterations = pending.Iterations
+	st.toolCalls = pending.ToolCalls
+
+	return a.run(ctx, st, newSendOpts(opts), &resumePoint{
+		step:      Step{Response: last},
+		decisions: byID,
+	})
+}
+
+// matchDecisions keys decisions by call id. Which calls need approval is
+// decided by the registered tools, not by pending, which is only checked
+// to describe the same tool request.
+func (a *Agent) matchDecisions(
+	reqs []ToolCallRequest,
+	pending PendingApproval,
+	decisions []ApprovalDecision,
+) (map[string]ApprovalDecision, error) {
+	calls, _ := a.pendingCalls(reqs)
+	if len(calls) != len(pending.Calls) {
+		return nil, fmt.Errorf("%w: pending calls do not match the tool request", ErrInvalidApproval)
+	}
+
+	needs := make(map[string]bool, len(calls))
+	for i, c := range calls {
+		if !samePendingCall(c, pending.Calls[i]) {
+			return nil, fmt.Errorf("%w: pending call %s does not match the tool request",
+				ErrInvalidApproval, pending.Calls[i].Request.Call.ID)
+		}
+		needs[c.Request.Call.ID] = c.NeedsApproval
+	}
+
+	byID := make(map[string]ApprovalDecision, len(decisions))
+	for _, d := range decisions {
+		if !needs[d.CallID] {
+			return nil, fmt.Errorf("%w: call %s does not await approval", ErrInvalidApproval, d.CallID)
+		}
+		byID[d.CallID] = d
+	}
+	for id, need := range needs {
+		if _, ok := byID[id]; need && !ok {
+			return nil, fmt.Errorf("%w: no decision for call %s", ErrInvalidApproval, id)
+		}
+	}
+
+	return byID, nil
+}
+
+// samePendingCall compares arguments by their compact form, since a pending
+// state stored as JSON loses the llm's formatting.
+func samePendingCall(a, b PendingCall) bool {
+	if a.Request.Call != b.Request.Call || a.NeedsApproval != b.NeedsApproval {
+		return false
+	}
+
+	var argsA, argsB bytes.Buffer
+	if json.Compact(&argsA, a.Request.Args) != nil || json.Compact(&argsB, b.Request.Args) != nil {
+		return bytes.Equal(a.Request.Args, b.Request.Args)
+	}
+	return bytes.Equal(argsA.Bytes(), argsB.Bytes())
+}
+
+func (a *Agent) pendingApproval(st *runState, reqs []ToolCallRequest) *PendingApproval {
+	calls, need := a.pendingCalls(reqs)
+	if !need {
+		return nil
+	}
+
+	return &PendingApproval{
+		Calls:      calls,
+		Iterations: st.iterations,
+		ToolCalls:  st.toolCalls,
+	}
+}
+
+// restorePending rebuilds the approval a stored history was suspended on,
+// which is the case when it ends with an unanswered tool request. The budget
+// consumed so far is counted back from the messages of the last turn.
+func (a *Agent) restorePending(history []Message) *PendingApproval {
+	if len(history) == 0 || !history[len(history)-1].IsToolCallRequest() {
+		return nil
+	}
+
+	calls, _ := a.pendingCalls(history[len(history)-1].MustToolCallRequests())
+	pending := &PendingApproval{Calls: calls}
+	for i := len(history) - 1; i >= 0 && history[i].Type() != MessageTypeUser; i-- {
+		if history[i].IsToolCallRequest() {
+			pending.Iterations++
+		}
+		if history[i].Type() == MessageTypeToolResponse {
+			pending.ToolCalls++
+		}
+	}
+
+	return pending
+}
+
+func (a *Agent) pendingCalls(reqs []ToolCallRequest) ([]PendingCall, bool) {
+	var (
+		calls = make([]PendingCall, 0, len(reqs))
+		need  bool
+	)
+	for _, req := range reqs {
+		t, ok := a.toolRegistry[req.Call.Name]
+		needs := ok && requiresApproval(t)
+		need = need || needs
+		calls = append(calls, PendingCall{Request: req, NeedsApproval: needs})
+	}
+
+	return calls, need
+}
+
+// applyDecisions returns the requests with edited arguments applied and the
+// results to send back for denied calls, keyed by call id.
+func applyDecisions(
+	reqs []ToolCallRequest,
+	decisions map[string]ApprovalDecision,
+) ([]ToolCallRequest, map[string]string) {
+	if len(decisions) == 0 {
+		return reqs, nil
+	}
+
+	var (
+		applied = make([]ToolCallRequest, 0, len(reqs))
+		denied  = make(map[string]string)
+	)
+	for _, req := range reqs {
+		d, ok := decisions[req.Call.ID]
+		switch {
+		case !ok:
+		case d.Verdict == ApprovalEdit:
+			req.Args = d.Args
+		case d.Verdict == ApprovalDeny:
+			denied[req.Call.ID] = deniedResult(d.Reason)
+		}
+		applied = append(applied, req)
+	}
+
+	return applied, denied
+}
+
+func deniedResult(reason string) string {
+	if reason == "" {
+		return "error: the call was denied by the user"
+	}
+	return "error: the call was denied by the user: " + reason
+}
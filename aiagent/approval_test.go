@@ -0,0 +1,101 @@
+package aiagent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func TestResumeRejectsMismatchedPending(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(p *aiagent.PendingApproval)
+	}{
+		{
+			name:   "approval flag cleared",
+			tamper: func(p *aiagent.PendingApproval) { p.Calls[0].NeedsApproval = false },
+		},
+		{
+			name:   "other call id",
+			tamper: func(p *aiagent.PendingApproval) { p.Calls[0].Request.Call.ID = "call_other" },
+		},
+		{
+			name:   "other arguments",
+			tamper: func(p *aiagent.PendingApproval) { p.Calls[0].Request.Args = []byte(`{"name":"b"}`) },
+		},
+		{
+			name:   "missing call",
+			tamper: func(p *aiagent.PendingApproval) { p.Calls = nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			step := aiagent.RequireApproval(aiagent.MustNewDerivedTool("step", "runs a step",
+				func(context.Context, stepArgs) (string, error) {
+					ran = true
+					return "done", nil
+				}))
+			llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("step", stepArgs{Name: "a"}))
+			agent := aiagent.NewAgent(llm, aiagent.WithTool(step))
+
+			res, err := agent.SendMessage(context.Background(), "go")
+			if err != nil || res.Pending == nil {
+				t.Fatalf("send = %s, %v, want a pending approval", res.StopReason, err)
+			}
+			tt.tamper(res.Pending)
+
+			_, err = agent.Resume(context.Background(), res.History, *res.Pending, nil)
+			if !errors.Is(err, aiagent.ErrInvalidApproval) {
+				t.Fatalf("err = %v, want %v", err, aiagent.ErrInvalidApproval)
+			}
+			if ran {
+				t.Error("tool ran without approval")
+			}
+		})
+	}
+}
+
+func TestResumeStoredPending(t *testing.T) {
+	step := aiagent.RequireApproval(aiagent.MustNewDerivedTool("step", "runs a step",
+		func(_ context.Context, args stepArgs) (string, error) {
+			return args.Name + " done", nil
+		}))
+	// the llm's formatting of the arguments is lost when pending is stored
+	req := aiagent.ToolCallRequest{
+		Call: aiagent.ToolCall{ID: "call_1", Name: "step"},
+		Args: []byte(`{ "name": "a" }`),
+	}
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTools(req),
+		aiagenttest.Reply("ok").Expecting(aiagenttest.ToolResult("step", "a done")),
+	)
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(step))
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	if err != nil || res.Pending == nil {
+		t.Fatalf("send = %s, %v, want a pending approval", res.StopReason, err)
+	}
+	data, err := json.Marshal(res.Pending)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var stored aiagent.PendingApproval
+	if err = json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	decisions := []aiagent.ApprovalDecision{aiagent.Approve("call_1")}
+	res, err = agent.Resume(context.Background(), res.History, stored, decisions)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if res.Text != "ok" {
+		t.Errorf("text = %q, want %q", res.Text, "ok")
+	}
+	llm.AssertDone()
+}
@@ -0,0 +1,127 @@
+package aiagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultMaxIterations = 10
+
+var (
+	ErrBudgetExceeded = errors.New("run budget exceeded")
+
+	errRunTimeout = errors.New("run timeout")
+)
+
+// Budget limits a single run. Zero fields are unlimited.
+type Budget struct {
+	// MaxIterations caps the number of llm calls.
+	MaxIterations int
+	// MaxToolCalls caps the total number of executed tool calls.
+	MaxToolCalls int
+	// MaxTokens caps the total tokens reported by the llm. It is checked
+	// before every llm call, so the last call may overshoot it.
+	MaxTokens int
+	// Timeout caps the wall-clock duration of the run.
+	Timeout time.Duration
+}
+
+// merge returns b with every non-zero field of override applied.
+func (b Budget) merge(override Budget) Budget {
+	if override.MaxIterations != 0 {
+		b.MaxIterations = override.MaxIterations
+	}
+	if override.MaxToolCalls != 0 {
+		b.MaxToolCalls = override.MaxToolCalls
+	}
+	if override.MaxTokens != 0 {
+		b.MaxTokens = override.MaxTokens
+	}
+	if override.Timeout != 0 {
+		b.Timeout = override.Timeout
+	}
+	return b
+}
+
+// WithBudget replaces the agent's default budget.
+func WithBudget(b Budget) AgentOption {
+	return func(a *Agent) {
+		a.budget = b
+	}
+}
+
+// WithSendBudget overrides the non-zero fields of the agent's budget for one run.
+func WithSendBudget(b Budget) SendOption {
+	return func(o *sendOpts) {
+		o.budget = o.budget.merge(b)
+	}
+}
+
+type BudgetLimit uint8
+
+const (
+	BudgetLimitIterations BudgetLimit = iota
+	BudgetLimitToolCalls
+	BudgetLimitTokens
+	BudgetLimitTimeout
+)
+
+func (l BudgetLimit) String() string {
+	switch l {
+	case BudgetLimitIterations:
+		return "iterations"
+	case BudgetLimitToolCalls:
+		return "tool_calls"
+	case BudgetLimitTokens:
+		return "tokens"
+	case BudgetLimitTimeout:
+		return "timeout"
+	default:
+		return fmt.Sprintf("unknown_budget_limit(%d)", l)
+	}
+}
+
+// BudgetExceededError carries the work done before the budget ran out.
+// History is always a valid conversation that can be passed to Send again
+// to continue the run.
+type BudgetExceededError struct {
+	Limit   BudgetLimit
+	History []Message
+	// LastText is the latest text the llm wrote since the last user message,
+	// typically alongside a tool request, or empty if there is none.
+	LastText string
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrBudgetExceeded, e.Limit)
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+func newBudgetExceededError(limit BudgetLimit, history []Message) *BudgetExceededError {
+	return &BudgetExceededError{
+		Limit:    limit,
+		History:  history,
+		LastText: lastLLMText(history),
+	}
+}
+
+func lastLLMText(history []Message) string {
+	for i := len(history) - 1; i >= 0 && history[i].Type() != MessageTypeUser; i-- {
+		if history[i].Type() != MessageTypeAssistant && history[i].Type() != MessageTypeToolRequest {
+			continue
+		}
+		if text, err := history[i].Text(); err == nil && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRunTimeout)
+}
@@ -0,0 +1,132 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+// lookupTurn requests one call of the step tool with a text and token usage.
+func lookupTurn(name string, tokens int) aiagenttest.Turn {
+	msg := aiagent.NewToolCallRequestMessageWithText("Looking up "+name+".",
+		[]aiagent.ToolCallRequest{aiagenttest.ToolCall("step", stepArgs{Name: name})})
+	return aiagenttest.Turn{Response: msg.WithUsage(aiagent.Usage{TotalTokens: tokens})}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   aiagent.Budget
+		turns    []aiagenttest.Turn
+		limit    aiagent.BudgetLimit
+		lastText string
+		// history is the length of the returned history
+		history int
+	}{
+		{
+			name:     "iterations",
+			budget:   aiagent.Budget{MaxIterations: 2},
+			turns:    []aiagenttest.Turn{lookupTurn("a", 0), lookupTurn("b", 0)},
+			limit:    aiagent.BudgetLimitIterations,
+			lastText: "Looking up b.",
+			history:  5,
+		},
+		{
+			name:     "tool calls",
+			budget:   aiagent.Budget{MaxToolCalls: 1},
+			turns:    []aiagenttest.Turn{lookupTurn("a", 0), lookupTurn("b", 0)},
+			limit:    aiagent.BudgetLimitToolCalls,
+			lastText: "Looking up a.",
+			history:  3,
+		},
+		{
+			name:     "tokens",
+			budget:   aiagent.Budget{MaxTokens: 100},
+			turns:    []aiagenttest.Turn{lookupTurn("a", 60), lookupTurn("b", 60)},
+			limit:    aiagent.BudgetLimitTokens,
+			lastText: "Looking up b.",
+			history:  5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := aiagenttest.NewFakeLLM(t, tt.turns...)
+			agent := aiagent.NewAgent(llm, aiagent.WithTool(stepTool()), aiagent.WithBudget(tt.budget))
+
+			res, err := agent.SendMessage(context.Background(), "go")
+			llm.AssertDone()
+
+			var budgetErr *aiagent.BudgetExceededError
+			if !errors.As(err, &budgetErr) || !errors.Is(err, aiagent.ErrBudgetExceeded) {
+				t.Fatalf("err = %v, want *BudgetExceededError", err)
+			}
+			if budgetErr.Limit != tt.limit {
+				t.Errorf("limit = %s, want %s", budgetErr.Limit, tt.limit)
+			}
+			if budgetErr.LastText != tt.lastText {
+				t.Errorf("last text = %q, want %q", budgetErr.LastText, tt.lastText)
+			}
+			if len(budgetErr.History) != tt.history {
+				t.Errorf("history has %d messages, want %d", len(budgetErr.History), tt.history)
+			}
+			if res.StopReason != aiagent.StopReasonBudgetExceeded {
+				t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonBudgetExceeded)
+			}
+		})
+	}
+}
+
+func TestBudgetTimeout(t *testing.T) {
+	wait := aiagent.MustNewDerivedTool("wait", "waits for the context",
+		func(ctx context.Context, _ struct{}) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+	// the fake llm ignores the context, so only the budget check can stop the run
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("wait", struct{}{}))
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(wait))
+
+	_, err := agent.SendMessage(context.Background(), "go",
+		aiagent.WithSendBudget(aiagent.Budget{Timeout: 20 * time.Millisecond}))
+	llm.AssertDone()
+
+	var budgetErr *aiagent.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("err = %v, want *BudgetExceededError", err)
+	}
+	if budgetErr.Limit != aiagent.BudgetLimitTimeout {
+		t.Errorf("limit = %s, want %s", budgetErr.Limit, aiagent.BudgetLimitTimeout)
+	}
+}
+
+func TestLastTextIgnoresEarlierTurns(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("step", stepArgs{Name: "a"}))
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(stepTool()),
+		aiagent.WithBudget(aiagent.Budget{MaxIterations: 1}))
+
+	chat := []aiagent.Message{
+		aiagent.NewUserMessage("hi"),
+		aiagent.NewAssistantMessage("Hello!"),
+		aiagent.NewUserMessage("go"),
+	}
+	_, err := agent.Send(context.Background(), chat)
+
+	var budgetErr *aiagent.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("err = %v, want *BudgetExceededError", err)
+	}
+	if budgetErr.LastText != "" {
+		t.Errorf("last text = %q, want none from earlier turns", budgetErr.LastText)
+	}
+}
+
+func stepTool() aiagent.Tool {
+	return aiagent.MustNewDerivedTool("step", "runs a step",
+		func(_ context.Context, args stepArgs) (string, error) {
+			return args.Name + " done", nil
+		})
+}
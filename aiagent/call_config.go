@@ -0,0 +1,36 @@
+package aiagent
+
+import "context"
+
+// CallConfig holds the settings of a single llm call. The agent passes it
+// to LLM.Call through the context; LLMs map what they support and ignore
+// the rest. LLM wrappers get it for free by passing the context on.
+type CallConfig struct {
+	// Generation is the agent's GenerationConfig with the run's overrides applied.
+	Generation GenerationConfig `json:"generation"`
+	// ToolChoice is resolved for the call, so FirstIterationOnly is never set.
+	ToolChoice ToolChoice `json:"tool_choice"`
+	// ResponseFormat asks for a final answer in JSON matching a schema.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+type ResponseFormat struct {
+	// Name identifies the schema, e.g. for the provider's logs.
+	Name string `json:"name"`
+	// Params are the properties of the expected JSON object.
+	Params []Param `json:"params"`
+}
+
+type callConfigKey struct{}
+
+// WithCallConfig returns a context carrying cfg to the llm.
+func WithCallConfig(ctx context.Context, cfg CallConfig) context.Context {
+	return context.WithValue(ctx, callConfigKey{}, cfg)
+}
+
+// CallConfigFrom returns the call config set by the agent,
+// or the zero config if there is none.
+func CallConfigFrom(ctx context.Context) CallConfig {
+	cfg, _ := ctx.Value(callConfigKey{}).(CallConfig)
+	return cfg
+}
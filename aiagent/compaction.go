@@ -0,0 +1,189 @@
+package aiagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	summaryPrefix = "Summary of the earlier conversation:\n"
+
+	defaultSummaryPrompt = "Summarize the conversation below for the assistant that continues it. " +
+		"Keep facts, decisions, open questions, tool results and user preferences that may matter later. " +
+		"Answer with the summary only."
+)
+
+var ErrNotSummary = errors.New("summarizer did not answer with text")
+
+// Compactor rewrites the history when it grows too large. Unlike HistoryPolicy,
+// the compacted history replaces the run's history and is returned in RunResult.
+// A nil Compaction means the history was left as is.
+type Compactor interface {
+	Compact(ctx context.Context, history []Message) ([]Message, *Compaction, error)
+}
+
+func WithCompactor(c Compactor) AgentOption {
+	return func(a *Agent) {
+		a.compactor = c
+	}
+}
+
+// Compaction records the messages that were folded into a summary.
+type Compaction struct {
+	Replaced []Message
+	Summary  Message
+}
+
+// SummaryCompactor asks an llm, possibly a cheaper one than the agent's,
+// to summarize older turns into a single system message once the history
+// exceeds Threshold estimated tokens. Leading system messages and the newest
+// KeepRecent messages are kept verbatim; earlier summaries, marked with
+// MetaSummary, are summarized again. Nothing is summarized when the messages
+// kept verbatim alone reach Threshold, as no summary could help then.
+type SummaryCompactor struct {
+	LLM       LLM
+	Threshold int
+	// KeepRecent is the number of newest messages kept verbatim. A tool request
+	// and its responses are never split, so slightly more may be kept.
+	KeepRecent int
+	// Counter estimates the size of a message, EstimateTokens if nil.
+	Counter func(Message) int
+	// Prompt instructs the summarizer, a default prompt is used if empty.
+	Prompt string
+}
+
+func (c SummaryCompactor) Compact(ctx context.Context, history []Message) ([]Message, *Compaction, error) {
+	count := c.Counter
+	if count == nil {
+		count = EstimateTokens
+	}
+
+	if countTokens(history, count) <= c.Threshold {
+		return history, nil, nil
+	}
+
+	units := groupHistory(history)
+
+	head := 0
+	for head < len(units) && isPinnedSystem(units[head].msgs[0]) {
+		head++
+	}
+
+	tail, kept := len(units), 0
+	for tail > head && (kept == 0 || kept+len(units[tail-1].msgs) <= c.KeepRecent) {
+		tail--
+		kept += len(units[tail].msgs)
+	}
+	if tail <= head {
+		return history, nil, nil
+	}
+
+	pinned := flatten(units[:head])
+	replaced := flatten(units[head:tail])
+	recent := flatten(units[tail:])
+	if countTokens(pinned, count)+countTokens(recent, count) >= c.Threshold {
+		return history, nil, nil
+	}
+
+	summary, err := c.summarize(ctx, replaced)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compacted := make([]Message, 0, len(pinned)+1+len(recent))
+	compacted = append(compacted, pinned...)
+	compacted = append(compacted, summary)
+	compacted = append(compacted, recent...)
+
+	return compacted, &Compaction{Replaced: replaced, Summary: summary}, nil
+}
+
+func (c SummaryCompactor) summarize(ctx context.Context, msgs []Message) (Message, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+
+	resp, err := c.LLM.Call(ctx, []Message{
+		NewSystemMessage(prompt),
+		NewUserMessage(renderTranscript(msgs)),
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("summarize history: %w", err)
+	}
+	if resp.Type() != MessageTypeAssistant {
+		return Message{}, ErrNotSummary
+	}
+
+	summary := NewSystemMessage(summaryPrefix + resp.MustText())
+	summary = summary.WithMeta(MetaSummary, "true")
+	summary = summary.WithUsage(resp.Usage())
+	if model, ok := resp.Meta(MetaModel); ok {
+		summary = summary.WithMeta(MetaModel, model)
+	}
+	return summary, nil
+}
+
+// isPinnedSystem reports system messages other than earlier summaries.
+func isPinnedSystem(m Message) bool {
+	_, summary := m.Meta(MetaSummary)
+	return m.Type() == MessageTypeSystem && !summary
+}
+
+func countTokens(msgs []Message, count func(Message) int) int {
+	var total int
+	for _, m := range msgs {
+		total += count(m)
+	}
+	return total
+}
+
+func flatten(units []historyUnit) []Message {
+	var msgs []Message
+	for _, u := range units {
+		msgs = append(msgs, u.msgs...)
+	}
+	return msgs
+}
+
+func renderTranscript(msgs []Message) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		switch m.Type() {
+		case MessageTypeSystem, MessageTypeUser, MessageTypeAssistant:
+			fmt.Fprintf(&b, "%s: %s\n", m.Type(), m.MustText())
+		case MessageTypeToolRequest:
+			if text, err := m.Text(); err == nil {
+				fmt.Fprintf(&b, "%s: %s\n", MessageTypeAssistant, text)
+			}
+			for _, req := range m.MustToolCallRequests() {
+				fmt.Fprintf(&b, "tool call: %s(%s)\n", req.Call.Name, req.Args)
+			}
+		case MessageTypeToolResponse:
+			resp := m.MustToolCallResponse()
+			fmt.Fprintf(&b, "tool result %s: %s\n", resp.Call.Name, resp.Result)
+		}
+	}
+	return b.String()
+}
+
+func (a *Agent) compact(ctx context.Context, st *runState) error {
+	if a.compactor == nil {
+		return nil
+	}
+
+	history, c, err := a.compactor.Compact(ctx, st.history)
+	if err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	if c == nil {
+		return nil
+	}
+
+	st.history = history
+	st.result.Compactions = append(st.result.Compactions, *c)
+	st.addUsage(c.Summary)
+	return nil
+}
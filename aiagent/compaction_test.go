@@ -0,0 +1,140 @@
+package aiagent_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+// one counts every message as a single token.
+func one(aiagent.Message) int { return 1 }
+
+func TestSummaryCompactor(t *testing.T) {
+	summarizer := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("they said hi"))
+	c := aiagent.SummaryCompactor{LLM: summarizer, Threshold: 4, KeepRecent: 2, Counter: one}
+
+	history := []aiagent.Message{
+		// a system prompt that merely looks like a summary is still pinned
+		aiagent.NewSystemMessage("Summary of the earlier conversation:\nbe brief"),
+		aiagent.NewUserMessage("hi"),
+		aiagent.NewAssistantMessage("hello"),
+		aiagent.NewUserMessage("how are you"),
+		aiagent.NewAssistantMessage("fine"),
+	}
+	compacted, compaction, err := c.Compact(context.Background(), history)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	summarizer.AssertDone()
+
+	if compaction == nil || len(compaction.Replaced) != 2 {
+		t.Fatalf("compaction = %+v, want the first exchange replaced", compaction)
+	}
+	got := labels(compacted)
+	if len(got) != 4 || got[0] != history[0].MustText() || got[2] != "how are you" {
+		t.Fatalf("compacted = %q, want the prompt, a summary and the recent turns", got)
+	}
+	if _, ok := compacted[1].Meta(aiagent.MetaSummary); !ok {
+		t.Errorf("summary %q is not marked with MetaSummary", got[1])
+	}
+	if !strings.Contains(got[1], "they said hi") {
+		t.Errorf("summary = %q, want the summarizer's answer", got[1])
+	}
+}
+
+func TestSummaryCompactorResummarizes(t *testing.T) {
+	summarizer := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("first"), aiagenttest.Reply("second"))
+	c := aiagent.SummaryCompactor{LLM: summarizer, Threshold: 3, KeepRecent: 1, Counter: one}
+
+	history := []aiagent.Message{
+		aiagent.NewUserMessage("u1"),
+		aiagent.NewAssistantMessage("a1"),
+		aiagent.NewUserMessage("u2"),
+		aiagent.NewAssistantMessage("a2"),
+	}
+	compacted, _, err := c.Compact(context.Background(), history)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	compacted = append(compacted, aiagent.NewUserMessage("u3"), aiagent.NewAssistantMessage("a3"))
+
+	compacted, compaction, err := c.Compact(context.Background(), compacted)
+	if err != nil {
+		t.Fatalf("second compact: %v", err)
+	}
+	summarizer.AssertDone()
+
+	if compaction == nil || len(compaction.Replaced) != 3 {
+		t.Fatalf("compaction = %+v, want the old summary replaced with its turns", compaction)
+	}
+	if got := labels(compacted); len(got) != 2 || !strings.HasSuffix(got[0], "second") || got[1] != "a3" {
+		t.Errorf("compacted = %q, want the new summary and the last message", got)
+	}
+}
+
+func TestSummaryCompactorSkipsHopelessCompaction(t *testing.T) {
+	// the summarizer has no turns, so any call fails the test
+	summarizer := aiagenttest.NewFakeLLM(t)
+	c := aiagent.SummaryCompactor{LLM: summarizer, Threshold: 3, KeepRecent: 3, Counter: one}
+
+	history := []aiagent.Message{
+		aiagent.NewSystemMessage("sys"),
+		aiagent.NewUserMessage("u1"),
+		aiagent.NewAssistantMessage("a1"),
+		aiagent.NewUserMessage("u2"),
+		aiagent.NewAssistantMessage("a2"),
+	}
+	compacted, compaction, err := c.Compact(context.Background(), history)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if compaction != nil || len(compacted) != len(history) {
+		t.Errorf("compacted to %q, want the history left as is", labels(compacted))
+	}
+}
+
+func TestSessionReplacesRewrittenTurns(t *testing.T) {
+	ctx := context.Background()
+	store := aiagent.NewMemoryStore()
+	if err := store.Append(ctx, "s", aiagent.NewUserMessage("my pin is 1234")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// the redaction keeps the history length, so only comparing
+	// the turns with the stored ones shows the rewrite
+	redact := aiagent.Interceptor{
+		BeforeLLMCall: func(_ context.Context, history []aiagent.Message) ([]aiagent.Message, error) {
+			history = slices.Clone(history)
+			for i, m := range history {
+				if m.Type() == aiagent.MessageTypeUser && strings.Contains(m.MustText(), "1234") {
+					history[i] = aiagent.NewUserMessage("my pin is [redacted]")
+				}
+			}
+			return history, nil
+		},
+	}
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello"))
+	agent := aiagent.NewAgent(llm, aiagent.WithInterceptor(redact))
+	session, err := agent.LoadSession(ctx, store, "s")
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+
+	if _, err = session.Send(ctx, "hi"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	stored, err := store.Load(ctx, "s")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := []string{"my pin is [redacted]", "hi", "hello"}
+	if got := labels(stored); !slices.Equal(got, want) {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package aiagent
+
+import "os"
+
+// Without flock, FileStore only serializes access within one process.
+func lockFile(*os.File, bool) error { return nil }
+
+func unlockFile(*os.File) {}
@@ -0,0 +1,93 @@
+package aiagent
+
+import "fmt"
+
+// GenerationConfig holds provider-neutral sampling parameters. Nil and zero
+// fields are left to the provider's defaults. LLMs map the fields they support
+// and ignore the others.
+type GenerationConfig struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	// MaxTokens caps the tokens generated by a single llm call,
+	// reasoning tokens included.
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	Seed             *int            `json:"seed,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	ReasoningEffort  ReasoningEffort `json:"reasoning_effort,omitempty"`
+}
+
+// Ptr returns a pointer to v, for the optional fields of GenerationConfig.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// merge returns g with every set field of override applied.
+func (g GenerationConfig) merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.MaxTokens != 0 {
+		g.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		g.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		g.Stop = override.Stop
+	}
+	if override.PresencePenalty != nil {
+		g.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		g.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.ReasoningEffort != ReasoningEffortDefault {
+		g.ReasoningEffort = override.ReasoningEffort
+	}
+	return g
+}
+
+// WithGeneration sets the agent's default generation parameters.
+func WithGeneration(g GenerationConfig) AgentOption {
+	return func(a *Agent) {
+		a.generation = g
+	}
+}
+
+// WithSendGeneration overrides the set fields of the agent's generation
+// parameters for one run.
+func WithSendGeneration(g GenerationConfig) SendOption {
+	return func(o *sendOpts) {
+		o.callConfig.Generation = o.callConfig.Generation.merge(g)
+	}
+}
+
+type ReasoningEffort uint8
+
+const (
+	// ReasoningEffortDefault leaves the effort to the provider.
+	ReasoningEffortDefault ReasoningEffort = iota
+	ReasoningEffortLow
+	ReasoningEffortMedium
+	ReasoningEffortHigh
+)
+
+func (e ReasoningEffort) String() string {
+	switch e {
+	case ReasoningEffortDefault:
+		return "default"
+	case ReasoningEffortLow:
+		return "low"
+	case ReasoningEffortMedium:
+		return "medium"
+	case ReasoningEffortHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("unknown_reasoning_effort(%d)", e)
+	}
+}
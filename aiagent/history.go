@@ -0,0 +1,152 @@
+package aiagent
+
+const (
+	// charsPerToken is a rough average for English text and JSON.
+	charsPerToken = 4
+	// messageTokenOverhead approximates the role and framing tokens of a message.
+	messageTokenOverhead = 4
+)
+
+// HistoryPolicy shapes the history before every llm call. It only changes what
+// the llm sees; the run keeps and returns the whole history.
+type HistoryPolicy interface {
+	Shape(history []Message) []Message
+}
+
+func WithHistoryPolicy(p HistoryPolicy) AgentOption {
+	return func(a *Agent) {
+		a.historyPolicy = p
+	}
+}
+
+// EstimateTokens approximates the number of tokens m takes in a prompt
+// without a tokenizer.
+func EstimateTokens(m Message) int {
+	var chars int
+	switch m.messageType {
+	case MessageTypeSystem, MessageTypeUser, MessageTypeAssistant:
+		chars = len(*m.text)
+	case MessageTypeToolRequest:
+		if m.text != nil {
+			chars = len(*m.text)
+		}
+		for _, req := range m.toolCallRequests {
+			chars += len(req.Call.ID) + len(req.Call.Name) + len(req.Args)
+		}
+	case MessageTypeToolResponse:
+		chars = len(m.toolCallResponse.Call.ID) + len(m.toolCallResponse.Result)
+	}
+
+	return messageTokenOverhead + (chars+charsPerToken-1)/charsPerToken
+}
+
+// HistoryWindow keeps the newest part of the history that fits its limits.
+// A tool request and its tool responses are kept or dropped together, and the
+// newest message is always kept even if it alone exceeds the limits.
+type HistoryWindow struct {
+	// MaxMessages limits the number of messages, pinned ones included.
+	// Zero means no limit.
+	MaxMessages int
+	// MaxTokens limits the estimated tokens, pinned messages included.
+	// Zero means no limit.
+	MaxTokens int
+	// Counter estimates the size of a message, EstimateTokens if nil.
+	Counter func(Message) int
+	// PinSystem keeps all system messages regardless of the limits.
+	PinSystem bool
+	// PinFirstUser keeps the first user message regardless of the limits.
+	PinFirstUser bool
+}
+
+// KeepLastN keeps the system messages, the first user message and
+// the newest messages up to n messages in total.
+func KeepLastN(n int) HistoryWindow {
+	return HistoryWindow{MaxMessages: n, PinSystem: true, PinFirstUser: true}
+}
+
+// TokenWindow keeps the system messages, the first user message and
+// the newest messages that fit into maxTokens estimated tokens.
+func TokenWindow(maxTokens int) HistoryWindow {
+	return HistoryWindow{MaxTokens: maxTokens, PinSystem: true, PinFirstUser: true}
+}
+
+func (w HistoryWindow) Shape(history []Message) []Message {
+	count := w.Counter
+	if count == nil {
+		count = EstimateTokens
+	}
+
+	units := groupHistory(history)
+	keep := make([]bool, len(units))
+
+	var messages, tokens int
+	firstUser := true
+	for i, u := range units {
+		pinned := w.PinSystem && u.msgs[0].Type() == MessageTypeSystem
+		if u.msgs[0].Type() == MessageTypeUser && firstUser {
+			firstUser = false
+			pinned = pinned || w.PinFirstUser
+		}
+		if pinned {
+			keep[i] = true
+			messages += len(u.msgs)
+			tokens += u.tokens(count)
+		}
+	}
+
+	for i := len(units) - 1; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		n, t := len(units[i].msgs), units[i].tokens(count)
+		fits := (w.MaxMessages <= 0 || messages+n <= w.MaxMessages) &&
+			(w.MaxTokens <= 0 || tokens+t <= w.MaxTokens)
+		if !fits && i != len(units)-1 {
+			break
+		}
+		keep[i] = true
+		messages += n
+		tokens += t
+	}
+
+	shaped := make([]Message, 0, messages)
+	for i, u := range units {
+		if keep[i] {
+			shaped = append(shaped, u.msgs...)
+		}
+	}
+
+	return shaped
+}
+
+// historyUnit is a run of messages that has to be kept or dropped as a whole.
+type historyUnit struct {
+	msgs []Message
+}
+
+func (u historyUnit) tokens(count func(Message) int) int {
+	return countTokens(u.msgs, count)
+}
+
+// groupHistory splits history into units, attaching tool responses
+// to the tool request they answer.
+func groupHistory(history []Message) []historyUnit {
+	units := make([]historyUnit, 0, len(history))
+	start := 0
+	for i := 1; i <= len(history); i++ {
+		if i < len(history) && history[i].Type() == MessageTypeToolResponse && history[start].IsToolCallRequest() {
+			continue
+		}
+		units = append(units, historyUnit{msgs: history[start:i]})
+		start = i
+	}
+
+	return units
+}
+
+func (a *Agent) shapeHistory(history []Message) []Message {
+	if a.historyPolicy == nil {
+		return history
+	}
+	return a.historyPolicy.Shape(history)
+}
@@ -0,0 +1,152 @@
+package aiagent_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+// toolTurnHistory has a tool request answered by two responses
+// in the middle of the conversation.
+func toolTurnHistory() []aiagent.Message {
+	return []aiagent.Message{
+		aiagent.NewSystemMessage("sys"),
+		aiagent.NewUserMessage("u1"),
+		aiagent.NewAssistantMessage("a1"),
+		aiagent.NewUserMessage("u2"),
+		aiagent.NewToolCallRequestMessage([]aiagent.ToolCallRequest{
+			withID(aiagenttest.ToolCall("step", stepArgs{Name: "a"}), "call_a"),
+			withID(aiagenttest.ToolCall("step", stepArgs{Name: "b"}), "call_b"),
+		}),
+		aiagent.NewToolCallResponseMessage("call_a", "step", "r1"),
+		aiagent.NewToolCallResponseMessage("call_b", "step", "r2"),
+		aiagent.NewAssistantMessage("a2"),
+	}
+}
+
+func withID(req aiagent.ToolCallRequest, id string) aiagent.ToolCallRequest {
+	req.Call.ID = id
+	return req
+}
+
+// labels names messages by their text, or "req" for tool requests.
+func labels(msgs []aiagent.Message) []string {
+	out := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		switch {
+		case m.IsToolCallRequest():
+			out = append(out, "req")
+		case m.IsToolCallResponse():
+			out = append(out, m.MustToolCallResponse().Result)
+		default:
+			out = append(out, m.MustText())
+		}
+	}
+	return out
+}
+
+// assertToolTurnsWhole fails when a kept tool request misses one of its
+// responses or a kept response misses its request.
+func assertToolTurnsWhole(t *testing.T, shaped []aiagent.Message) {
+	t.Helper()
+
+	answered := make(map[string]bool)
+	for i, m := range shaped {
+		if m.IsToolCallResponse() {
+			if id := m.MustToolCallResponse().Call.ID; !answered[id] {
+				t.Errorf("response %s kept without its request: %q", id, labels(shaped))
+			}
+			continue
+		}
+		if !m.IsToolCallRequest() {
+			continue
+		}
+		for j, req := range m.MustToolCallRequests() {
+			k := i + 1 + j
+			if k >= len(shaped) || !shaped[k].IsToolCallResponse() ||
+				shaped[k].MustToolCallResponse().Call.ID != req.Call.ID {
+				t.Errorf("request kept without its response %s: %q", req.Call.ID, labels(shaped))
+			}
+			answered[req.Call.ID] = true
+		}
+	}
+}
+
+func TestKeepLastN(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 3, want: []string{"sys", "u1", "a2"}},
+		// the tool turn takes three messages and is not split to fill the window
+		{n: 5, want: []string{"sys", "u1", "a2"}},
+		{n: 6, want: []string{"sys", "u1", "req", "r1", "r2", "a2"}},
+		{n: 7, want: []string{"sys", "u1", "u2", "req", "r1", "r2", "a2"}},
+		{n: 8, want: []string{"sys", "u1", "a1", "u2", "req", "r1", "r2", "a2"}},
+	}
+	for _, tt := range tests {
+		got := labels(aiagent.KeepLastN(tt.n).Shape(toolTurnHistory()))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("KeepLastN(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryWindowKeepsNewestToolTurn(t *testing.T) {
+	// a run that just got its tool responses back must send all of them
+	history := toolTurnHistory()
+	history = history[:len(history)-1]
+
+	got := labels(aiagent.KeepLastN(1).Shape(history))
+	want := []string{"sys", "u1", "req", "r1", "r2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("shaped = %q, want %q", got, want)
+	}
+}
+
+func TestTokenWindow(t *testing.T) {
+	w := aiagent.TokenWindow(6)
+	w.Counter = func(aiagent.Message) int { return 1 }
+
+	got := labels(w.Shape(toolTurnHistory()))
+	want := []string{"sys", "u1", "req", "r1", "r2", "a2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("shaped = %q, want %q", got, want)
+	}
+
+	w.Counter = func(m aiagent.Message) int {
+		if m.IsToolCallResponse() {
+			return 10
+		}
+		return 1
+	}
+	got = labels(w.Shape(toolTurnHistory()))
+	want = []string{"sys", "u1", "a2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("shaped with large responses = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryWindowNeverSplitsToolTurns(t *testing.T) {
+	history := toolTurnHistory()
+	for n := range len(history) + 1 {
+		assertToolTurnsWhole(t, aiagent.KeepLastN(n).Shape(history))
+		assertToolTurnsWhole(t, aiagent.KeepLastN(n).Shape(history[:len(history)-1]))
+
+		w := aiagent.TokenWindow(n * 5)
+		assertToolTurnsWhole(t, w.Shape(history))
+		assertToolTurnsWhole(t, w.Shape(history[:len(history)-1]))
+	}
+}
+
+func TestHistoryWindowWithoutPins(t *testing.T) {
+	w := aiagent.HistoryWindow{MaxMessages: 2}
+
+	got := labels(w.Shape(toolTurnHistory()))
+	want := []string{"a2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("shaped = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package aiagent
+
+import (
+	"context"
+	"fmt"
+)
+
+// Interceptor hooks into the run loop. Nil hooks are skipped. A hook returning
+// an error aborts the run with that error. Interceptors run in registration order.
+type Interceptor struct {
+	// BeforeLLMCall may return a modified history, which replaces the run's history.
+	BeforeLLMCall func(ctx context.Context, history []Message) ([]Message, error)
+	// AfterLLMCall may replace the llm response before the agent acts on it.
+	AfterLLMCall func(ctx context.Context, resp Message) (Message, error)
+	// BeforeToolCall may rewrite the request. A non-nil result skips the tool
+	// and is sent to the llm instead. Tool hooks may run concurrently,
+	// see WithToolConcurrency.
+	BeforeToolCall func(ctx context.Context, req ToolCallRequest) (ToolCallRequest, *string, error)
+	// AfterToolCall may modify the record, e.g. its Result, before it is added to history.
+	AfterToolCall func(ctx context.Context, rec ToolCallRecord) (ToolCallRecord, error)
+	// OnFinish is called once with the outcome of every run.
+	OnFinish func(ctx context.Context, result RunResult, err error)
+}
+
+func WithInterceptor(i Interceptor) AgentOption {
+	return func(a *Agent) {
+		a.interceptors = append(a.interceptors, i)
+	}
+}
+
+func (a *Agent) beforeLLMCall(ctx context.Context, history []Message) ([]Message, error) {
+	for _, i := range a.interceptors {
+		if i.BeforeLLMCall == nil {
+			continue
+		}
+		var err error
+		if history, err = i.BeforeLLMCall(ctx, history); err != nil {
+			return nil, fmt.Errorf("before llm call: %w", err)
+		}
+	}
+	return history, nil
+}
+
+func (a *Agent) afterLLMCall(ctx context.Context, resp Message) (Message, error) {
+	for _, i := range a.interceptors {
+		if i.AfterLLMCall == nil {
+			continue
+		}
+		var err error
+		if resp, err = i.AfterLLMCall(ctx, resp); err != nil {
+			return Message{}, fmt.Errorf("after llm call: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+func (a *Agent) beforeToolCall(ctx context.Context, req ToolCallRequest) (ToolCallRequest, *string, error) {
+	for _, i := range a.interceptors {
+		if i.BeforeToolCall == nil {
+			continue
+		}
+		next, result, err := i.BeforeToolCall(ctx, req)
+		if err != nil {
+			return ToolCallRequest{}, nil, fmt.Errorf("before tool call %s: %w", req.Call.Name, err)
+		}
+		req = next
+		if result != nil {
+			return req, result, nil
+		}
+	}
+	return req, nil, nil
+}
+
+func (a *Agent) afterToolCall(ctx context.Context, rec ToolCallRecord) (ToolCallRecord, error) {
+	for _, i := range a.interceptors {
+		if i.AfterToolCall == nil {
+			continue
+		}
+		next, err := i.AfterToolCall(ctx, rec)
+		if err != nil {
+			return ToolCallRecord{}, fmt.Errorf("after tool call %s: %w", rec.Request.Call.Name, err)
+		}
+		rec = next
+	}
+	return rec, nil
+}
+
+func (a *Agent) onFinish(ctx context.Context, result RunResult, err error) {
+	for _, i := range a.interceptors {
+		if i.OnFinish != nil {
+			i.OnFinish(ctx, result, err)
+		}
+	}
+}
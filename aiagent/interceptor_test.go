@@ -0,0 +1,206 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func TestInterceptorHooks(t *testing.T) {
+	var order []string
+	record := func(name string) aiagent.Interceptor {
+		return aiagent.Interceptor{
+			BeforeLLMCall: func(_ context.Context, history []aiagent.Message) ([]aiagent.Message, error) {
+				order = append(order, name+" before llm")
+				return history, nil
+			},
+			AfterLLMCall: func(_ context.Context, resp aiagent.Message) (aiagent.Message, error) {
+				order = append(order, name+" after llm")
+				return resp, nil
+			},
+			BeforeToolCall: func(
+				_ context.Context, req aiagent.ToolCallRequest,
+			) (aiagent.ToolCallRequest, *string, error) {
+				order = append(order, name+" before tool")
+				return req, nil, nil
+			},
+			AfterToolCall: func(_ context.Context, rec aiagent.ToolCallRecord) (aiagent.ToolCallRecord, error) {
+				order = append(order, name+" after tool")
+				rec.Result += " by " + name
+				return rec, nil
+			},
+			OnFinish: func(context.Context, aiagent.RunResult, error) {
+				order = append(order, name+" finish")
+			},
+		}
+	}
+
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTool("step", stepArgs{Name: "a"}),
+		aiagenttest.Reply("done").Expecting(aiagenttest.ToolResult("step", "a done by first by second")),
+	)
+	agent := aiagent.NewAgent(llm,
+		aiagent.WithTool(stepTool()),
+		aiagent.WithInterceptor(record("first")),
+		aiagent.WithInterceptor(record("second")),
+	)
+
+	if _, err := agent.SendMessage(context.Background(), "go"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	want := []string{
+		"first before llm", "second before llm", "first after llm", "second after llm",
+		"first before tool", "second before tool", "first after tool", "second after tool",
+		"first before llm", "second before llm", "first after llm", "second after llm",
+		"first finish", "second finish",
+	}
+	if !slices.Equal(order, want) {
+		t.Errorf("hooks ran in order\n%q\nwant\n%q", order, want)
+	}
+}
+
+func TestInterceptorRewrites(t *testing.T) {
+	skipped := "skipped"
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTools(
+			aiagenttest.ToolCall("step", stepArgs{Name: "a"}),
+			aiagenttest.ToolCall("step", stepArgs{Name: "skip"}),
+		),
+		aiagenttest.Reply("done").Expecting(aiagenttest.HistoryLen(4), aiagenttest.LastUserText("go, rewritten")),
+	)
+	agent := aiagent.NewAgent(llm,
+		aiagent.WithTool(stepTool()),
+		aiagent.WithInterceptor(aiagent.Interceptor{
+			BeforeLLMCall: func(_ context.Context, history []aiagent.Message) ([]aiagent.Message, error) {
+				history = slices.Clone(history)
+				history[0] = aiagent.NewUserMessage("go, rewritten")
+				return history, nil
+			},
+			BeforeToolCall: func(
+				_ context.Context, req aiagent.ToolCallRequest,
+			) (aiagent.ToolCallRequest, *string, error) {
+				if string(req.Args) == `{"name":"skip"}` {
+					return req, &skipped, nil
+				}
+				req.Args = []byte(`{"name":"b"}`)
+				return req, nil, nil
+			},
+		}),
+	)
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	calls := aiagenttest.ToolCalls(res)
+	if len(calls) != 2 || calls[0].Result != "b done" || calls[1].Result != skipped {
+		t.Errorf("tool calls = %+v, want the rewritten call and the skipped one", calls)
+	}
+}
+
+func TestInterceptorError(t *testing.T) {
+	errHook := errors.New("hook failed")
+	tests := []struct {
+		name        string
+		interceptor aiagent.Interceptor
+	}{
+		{
+			name: "before llm call",
+			interceptor: aiagent.Interceptor{
+				BeforeLLMCall: func(context.Context, []aiagent.Message) ([]aiagent.Message, error) {
+					return nil, errHook
+				},
+			},
+		},
+		{
+			name: "after llm call",
+			interceptor: aiagent.Interceptor{
+				AfterLLMCall: func(context.Context, aiagent.Message) (aiagent.Message, error) {
+					return aiagent.Message{}, errHook
+				},
+			},
+		},
+		{
+			name: "before tool call",
+			interceptor: aiagent.Interceptor{
+				BeforeToolCall: func(
+					context.Context, aiagent.ToolCallRequest,
+				) (aiagent.ToolCallRequest, *string, error) {
+					return aiagent.ToolCallRequest{}, nil, errHook
+				},
+			},
+		},
+		{
+			name: "after tool call",
+			interceptor: aiagent.Interceptor{
+				AfterToolCall: func(context.Context, aiagent.ToolCallRecord) (aiagent.ToolCallRecord, error) {
+					return aiagent.ToolCallRecord{}, errHook
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var finished []error
+			llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("step", stepArgs{Name: "a"}))
+			agent := aiagent.NewAgent(llm,
+				aiagent.WithTool(stepTool()),
+				aiagent.WithInterceptor(tt.interceptor),
+				aiagent.WithInterceptor(aiagent.Interceptor{
+					OnFinish: func(_ context.Context, _ aiagent.RunResult, err error) {
+						finished = append(finished, err)
+					},
+				}),
+			)
+
+			res, err := agent.SendMessage(context.Background(), "go")
+			if !errors.Is(err, errHook) {
+				t.Fatalf("err = %v, want %v", err, errHook)
+			}
+			if res.StopReason != aiagent.StopReasonError {
+				t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonError)
+			}
+			if len(finished) != 1 || !errors.Is(finished[0], errHook) {
+				t.Errorf("OnFinish got %v, want the hook error once", finished)
+			}
+		})
+	}
+}
+
+func TestAfterLLMCallInvalidResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		resp aiagent.Message
+	}{
+		{name: "zero message", resp: aiagent.Message{}},
+		{name: "user message", resp: aiagent.NewUserMessage("hi")},
+		{name: "tool response", resp: aiagent.NewToolCallResponseMessage("call_1", "step", "done")},
+		{name: "no tool calls", resp: aiagent.NewToolCallRequestMessage(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello"))
+			agent := aiagent.NewAgent(llm, aiagent.WithInterceptor(aiagent.Interceptor{
+				AfterLLMCall: func(context.Context, aiagent.Message) (aiagent.Message, error) {
+					return tt.resp, nil
+				},
+			}))
+
+			res, err := agent.SendMessage(context.Background(), "go")
+			if !errors.Is(err, aiagent.ErrInvalidResponse) {
+				t.Fatalf("err = %v, want %v", err, aiagent.ErrInvalidResponse)
+			}
+			if res.StopReason != aiagent.StopReasonError {
+				t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonError)
+			}
+		})
+	}
+}
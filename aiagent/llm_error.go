@@ -0,0 +1,87 @@
+package aiagent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type LLMErrorClass uint8
+
+const (
+	LLMErrorUnknown LLMErrorClass = iota
+	// LLMErrorRateLimit means the provider throttled the request.
+	LLMErrorRateLimit
+	// LLMErrorQuota means the account ran out of credits.
+	LLMErrorQuota
+	// LLMErrorServer is a provider side failure, e.g. a 5xx response.
+	LLMErrorServer
+	// LLMErrorNetwork is a transport failure before a response was received.
+	LLMErrorNetwork
+	LLMErrorAuth
+	LLMErrorInvalidRequest
+	// LLMErrorContextLength means the prompt does not fit the model's context window.
+	LLMErrorContextLength
+)
+
+func (c LLMErrorClass) String() string {
+	switch c {
+	case LLMErrorUnknown:
+		return "unknown"
+	case LLMErrorRateLimit:
+		return "rate_limit"
+	case LLMErrorQuota:
+		return "quota"
+	case LLMErrorServer:
+		return "server"
+	case LLMErrorNetwork:
+		return "network"
+	case LLMErrorAuth:
+		return "auth"
+	case LLMErrorInvalidRequest:
+		return "invalid_request"
+	case LLMErrorContextLength:
+		return "context_length"
+	default:
+		return fmt.Sprintf("unknown_llm_error_class(%d)", c)
+	}
+}
+
+// Transient reports whether the same request may succeed when sent again.
+func (c LLMErrorClass) Transient() bool {
+	//nolint:exhaustive // the other classes are permanent
+	switch c {
+	case LLMErrorRateLimit, LLMErrorServer, LLMErrorNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// LLMError is returned by LLM implementations to describe a failed call
+// independently of the provider.
+type LLMError struct {
+	Class      LLMErrorClass
+	StatusCode int
+	// RetryAfter is the delay requested by the provider, zero if none.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyLLMError returns the class of the LLMError in err's chain,
+// or LLMErrorUnknown if there is none.
+func ClassifyLLMError(err error) LLMErrorClass {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.Class
+	}
+	return LLMErrorUnknown
+}
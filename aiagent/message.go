@@ -4,10 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 
 	"github.com/fatih/color"
 )
 
+const (
+	// MetaModel is the metadata key holding the model that produced a message.
+	MetaModel = "model"
+	// MetaSummary is the metadata key marking the summaries written by
+	// SummaryCompactor.
+	MetaSummary = "summary"
+)
+
 var (
 	ErrNoTextContent       = errors.New("message has no text content")
 	ErrNotToolCallResponse = errors.New("message is not a tool call response")
@@ -19,6 +28,10 @@ type Message struct {
 	toolCallRequests []ToolCallRequest
 	toolCallResponse *ToolCallResponse
 	messageType      MessageType
+	usage            Usage
+	meta             map[string]string
+	// extra keeps JSON fields unknown to this version, see MarshalJSON.
+	extra map[string]json.RawMessage
 }
 
 func NewUserMessage(text string) Message {
@@ -49,6 +62,16 @@ func NewToolCallRequestMessage(requests []ToolCallRequest) Message {
 	}
 }
 
+// NewToolCallRequestMessageWithText is a tool request carrying the text the
+// llm wrote along with its calls. Text returns it.
+func NewToolCallRequestMessageWithText(text string, requests []ToolCallRequest) Message {
+	return Message{
+		text:             &text,
+		toolCallRequests: requests,
+		messageType:      MessageTypeToolRequest,
+	}
+}
+
 func NewToolCallResponseMessage(toolID string, toolName string, result string) Message {
 	return Message{
 		toolCallResponse: &ToolCallResponse{
@@ -62,6 +85,32 @@ func NewToolCallResponseMessage(toolID string, toolName string, result string) M
 	}
 }
 
+// WithUsage returns a copy of m carrying the token usage of the llm call that produced it.
+func (m *Message) WithUsage(u Usage) Message {
+	c := *m
+	c.usage = u
+	return c
+}
+
+func (m *Message) Usage() Usage {
+	return m.usage
+}
+
+// WithMeta returns a copy of m with the metadata key set to value,
+// see MetaModel and MetaSummary for the keys set by this module.
+func (m *Message) WithMeta(key string, value string) Message {
+	c := *m
+	c.meta = make(map[string]string, len(m.meta)+1)
+	maps.Copy(c.meta, m.meta)
+	c.meta[key] = value
+	return c
+}
+
+func (m *Message) Meta(key string) (string, bool) {
+	v, ok := m.meta[key]
+	return v, ok
+}
+
 func (m *Message) Text() (string, error) {
 	if m.text == nil {
 		return "", fmt.Errorf("%w (type=%s)", ErrNoTextContent, m.Type())
@@ -142,6 +191,9 @@ func (m *Message) String() string {
 			text = text[:textLimit] + "..."
 		}
 	case MessageTypeToolRequest:
+		if m.text != nil {
+			text = "\n" + *m.text
+		}
 		for _, req := range m.toolCallRequests {
 			text += fmt.Sprintf("\n\t%s(%s)", req.Call.Name, string(req.Args))
 		}
@@ -161,18 +213,18 @@ func (m *Message) String() string {
 }
 
 type ToolCall struct {
-	ID   string
-	Name string
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type ToolCallRequest struct {
-	Call ToolCall
-	Args json.RawMessage
+	Call ToolCall        `json:"call"`
+	Args json.RawMessage `json:"args"`
 }
 
 type ToolCallResponse struct {
-	Call   ToolCall
-	Result string
+	Call   ToolCall `json:"call"`
+	Result string   `json:"result"`
 }
 
 type MessageType uint8
@@ -0,0 +1,154 @@
+package aiagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MessageSchemaVersion is the version written by Message.MarshalJSON.
+const MessageSchemaVersion = 1
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// messageJSON is the wire form of Message, schema version 1:
+//
+//	{
+//	  "version": 1,
+//	  "type": "system" | "user" | "assistant" | "tool_request" | "tool_response",
+//	  "text": "...",                                      // system, user, assistant, tool_request (optional)
+//	  "tool_calls": [{"call": {"id": "...", "name": "..."}, "args": {...}}], // tool_request
+//	  "tool_response": {"call": {"id": "...", "name": "..."}, "result": "..."}, // tool_response
+//	  "usage": {                                          // optional
+//	    "prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0,
+//	    "cached_tokens": 0, "reasoning_tokens": 0          // optional
+//	  },
+//	  "meta": {"model": "..."}                            // optional
+//	}
+//
+// Fields are only ever added to the schema. Unknown fields are kept on decode
+// and written back on encode, so messages survive a round trip through an
+// older version of this package.
+type messageJSON struct {
+	Version      int               `json:"version"`
+	Type         string            `json:"type"`
+	Text         *string           `json:"text,omitempty"`
+	ToolCalls    []ToolCallRequest `json:"tool_calls,omitempty"`
+	ToolResponse *ToolCallResponse `json:"tool_response,omitempty"`
+	Usage        *Usage            `json:"usage,omitempty"`
+	Meta         map[string]string `json:"meta,omitempty"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	wire := messageJSON{
+		Version:      MessageSchemaVersion,
+		Type:         m.messageType.String(),
+		Text:         m.text,
+		ToolCalls:    m.toolCallRequests,
+		ToolResponse: m.toolCallResponse,
+		Meta:         m.meta,
+	}
+	if m.usage != (Usage{}) {
+		wire.Usage = &m.usage
+	}
+
+	data, err := json.Marshal(wire)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.extra) == 0 {
+		return data, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range m.extra {
+		fields[k] = v
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON accepts any schema version. Later versions only add fields,
+// so a message written by a newer version of this package decodes with the
+// fields it does not know kept as they are.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var wire messageJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var extra map[string]json.RawMessage
+	for k, v := range fields {
+		if isMessageJSONField(k) {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[k] = v
+	}
+
+	msgType, err := parseMessageType(wire.Type)
+	if err != nil {
+		return err
+	}
+
+	decoded := Message{
+		text:             wire.Text,
+		toolCallRequests: wire.ToolCalls,
+		toolCallResponse: wire.ToolResponse,
+		messageType:      msgType,
+		meta:             wire.Meta,
+		extra:            extra,
+	}
+	if wire.Usage != nil {
+		decoded.usage = *wire.Usage
+	}
+	if err = decoded.validate(); err != nil {
+		return err
+	}
+
+	*m = decoded
+	return nil
+}
+
+func isMessageJSONField(name string) bool {
+	switch name {
+	case "version", "type", "text", "tool_calls", "tool_response", "usage", "meta":
+		return true
+	}
+	return false
+}
+
+func (m *Message) validate() error {
+	switch m.messageType {
+	case MessageTypeSystem, MessageTypeUser, MessageTypeAssistant:
+		if m.text == nil {
+			return fmt.Errorf("%w: %s message without text", ErrInvalidMessage, m.messageType)
+		}
+	case MessageTypeToolRequest:
+		if len(m.toolCallRequests) == 0 {
+			return fmt.Errorf("%w: tool_request message without tool_calls", ErrInvalidMessage)
+		}
+	case MessageTypeToolResponse:
+		if m.toolCallResponse == nil {
+			return fmt.Errorf("%w: tool_response message without tool_response", ErrInvalidMessage)
+		}
+	}
+	return nil
+}
+
+func parseMessageType(s string) (MessageType, error) {
+	for t := MessageTypeSystem; t <= MessageTypeToolResponse; t++ {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown type %q", ErrInvalidMessage, s)
+}
@@ -0,0 +1,106 @@
+package aiagent_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	usage := aiagent.Usage{
+		PromptTokens:     12,
+		CompletionTokens: 5,
+		TotalTokens:      17,
+		CachedTokens:     4,
+		ReasoningTokens:  2,
+	}
+	request := aiagent.NewToolCallRequestMessage([]aiagent.ToolCallRequest{
+		{Call: aiagent.ToolCall{ID: "call_1", Name: "weather"}, Args: json.RawMessage(`{"city":"Oslo"}`)},
+		{Call: aiagent.ToolCall{ID: "call_2", Name: "time"}, Args: json.RawMessage(`{}`)},
+	})
+	assistant := aiagent.NewAssistantMessage("It is sunny.")
+
+	tests := []struct {
+		name string
+		msg  aiagent.Message
+	}{
+		{name: "system", msg: aiagent.NewSystemMessage("You are helpful.")},
+		{name: "user", msg: aiagent.NewUserMessage("What is the weather?")},
+		{name: "assistant", msg: assistant},
+		{name: "empty text", msg: aiagent.NewAssistantMessage("")},
+		{name: "tool request", msg: request},
+		{name: "tool response", msg: aiagent.NewToolCallResponseMessage("call_1", "weather", "sunny")},
+		{name: "usage", msg: request.WithUsage(usage)},
+		{name: "meta", msg: assistant.WithMeta(aiagent.MetaModel, "gpt-4o")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var got aiagent.Message
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal %s: %v", data, err)
+			}
+			if !reflect.DeepEqual(got, tt.msg) {
+				t.Errorf("round trip of %s:\ngot  %#v\nwant %#v", data, got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMessageJSONUnknownFields(t *testing.T) {
+	in := `{"version":2,"type":"assistant","text":"hi","citations":[{"url":"https://example.com"}],"score":0.5}`
+
+	var m aiagent.Message
+	if err := json.Unmarshal([]byte(in), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := m.MustText(); got != "hi" {
+		t.Errorf("text = %q, want %q", got, "hi")
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal fields: %v", err)
+	}
+	if got := string(fields["citations"]); got != `[{"url":"https://example.com"}]` {
+		t.Errorf("citations = %s", got)
+	}
+	if got := string(fields["score"]); got != `0.5` {
+		t.Errorf("score = %s", got)
+	}
+	if got := string(fields["version"]); got != `1` {
+		t.Errorf("version = %s, want 1", got)
+	}
+}
+
+func TestMessageJSONInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "unknown type", in: `{"version":1,"type":"function","text":"x"}`},
+		{name: "user without text", in: `{"version":1,"type":"user"}`},
+		{name: "tool request without calls", in: `{"version":1,"type":"tool_request"}`},
+		{name: "tool response without response", in: `{"version":1,"type":"tool_response"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m aiagent.Message
+			err := json.Unmarshal([]byte(tt.in), &m)
+			if !errors.Is(err, aiagent.ErrInvalidMessage) {
+				t.Errorf("err = %v, want %v", err, aiagent.ErrInvalidMessage)
+			}
+		})
+	}
+}
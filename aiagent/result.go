@@ -0,0 +1,72 @@
+package aiagent
+
+import (
+	"fmt"
+	"time"
+)
+
+type RunResult struct {
+	// Text is the final assistant answer.
+	Text string
+	// Message is the final assistant message.
+	Message Message
+	// History is the whole conversation, including the input chat.
+	History []Message
+	Steps   []Step
+	// Usage is the token usage summed over all llm calls of the run,
+	// UsageByModel splits it by the model that reported it.
+	Usage        Usage
+	UsageByModel UsageByModel
+	StopReason   StopReason
+	// ToolErrors lists tool failures that were reported back to the llm
+	// instead of aborting the run, in the order they happened.
+	ToolErrors []ToolError
+	// Compactions lists the history compactions made during the run.
+	Compactions []Compaction
+	// Pending is set when StopReason is StopReasonPendingApproval,
+	// see Agent.Resume.
+	Pending *PendingApproval
+}
+
+// Step is a single llm call together with the tool calls it requested.
+type Step struct {
+	Response   Message
+	LLMLatency time.Duration
+	ToolCalls  []ToolCallRecord
+}
+
+type ToolCallRecord struct {
+	Request ToolCallRequest
+	// Result is the content sent back to the llm.
+	Result   string
+	Err      error
+	Duration time.Duration
+}
+
+func (r ToolCallRecord) message() Message {
+	return NewToolCallResponseMessage(r.Request.Call.ID, r.Request.Call.Name, r.Result)
+}
+
+type StopReason uint8
+
+const (
+	StopReasonFinalAnswer StopReason = iota
+	StopReasonBudgetExceeded
+	StopReasonError
+	StopReasonPendingApproval
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopReasonFinalAnswer:
+		return "final_answer"
+	case StopReasonBudgetExceeded:
+		return "budget_exceeded"
+	case StopReasonError:
+		return "error"
+	case StopReasonPendingApproval:
+		return "pending_approval"
+	default:
+		return fmt.Sprintf("unknown_stop_reason(%d)", r)
+	}
+}
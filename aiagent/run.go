@@ -0,0 +1,364 @@
+package aiagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrInvalidResponse is returned when the llm, or an AfterLLMCall
+// interceptor, answers with neither text nor tool calls.
+var ErrInvalidResponse = errors.New("llm response is neither text nor tool calls")
+
+type runState struct {
+	history    []Message
+	result     RunResult
+	unknown    []ToolCallRequest
+	iterations int
+	toolCalls  int
+}
+
+func newRunState(chat []Message) *runState {
+	return &runState{
+		history: slices.Clone(chat),
+		result:  RunResult{UsageByModel: make(UsageByModel)},
+	}
+}
+
+// resumePoint is a tool request turn suspended for approval.
+type resumePoint struct {
+	step      Step
+	decisions map[string]ApprovalDecision
+}
+
+func (a *Agent) run(ctx context.Context, st *runState, so sendOpts, resume *resumePoint) (RunResult, error) {
+	result, err := a.loop(ctx, st, so, resume)
+	a.onFinish(ctx, result, err)
+
+	return result, err
+}
+
+func (a *Agent) loop(ctx context.Context, st *runState, so sendOpts, resume *resumePoint) (RunResult, error) {
+	if err := a.validateToolChoice(so.toolChoice); err != nil {
+		return st.stop(StopReasonError), err
+	}
+
+	budget := a.budget.merge(so.budget)
+	if budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, budget.Timeout, errRunTimeout)
+		defer cancel()
+	}
+
+	if a.debug {
+		defer func() {
+			a.printHistory(st.history)
+			fmt.Println()
+		}()
+	}
+
+	if resume != nil {
+		if stop, errTurn := a.toolTurn(ctx, st, budget, resume.step, resume.decisions); stop {
+			return st.result, errTurn
+		}
+	}
+
+	for {
+		if err := st.checkBudget(ctx, budget); err != nil {
+			return st.stop(StopReasonBudgetExceeded), err
+		}
+
+		if err := a.compact(ctx, st); err != nil {
+			return st.fail(ctx, err)
+		}
+
+		history, errHook := a.beforeLLMCall(ctx, st.history)
+		if errHook != nil {
+			return st.stop(StopReasonError), errHook
+		}
+		st.history = history
+
+		start := time.Now()
+		resp, errCall := a.callLLM(ctx, a.shapeHistory(st.history), so, st.iterations)
+		if errCall != nil {
+			return st.fail(ctx, fmt.Errorf("call llm: %w", errCall))
+		}
+		latency := time.Since(start)
+
+		resp, errHook = a.afterLLMCall(ctx, resp)
+		if errHook != nil {
+			return st.stop(StopReasonError), errHook
+		}
+		st.iterations++
+		st.addUsage(resp)
+		step := Step{Response: resp, LLMLatency: latency}
+
+		if err := checkResponse(resp); err != nil {
+			st.result.Steps = append(st.result.Steps, step)
+			return st.stop(StopReasonError), err
+		}
+		if resp.Type() == MessageTypeAssistant {
+			st.history = append(st.history, resp)
+			st.result.Steps = append(st.result.Steps, step)
+			st.result.Message = resp
+			st.result.Text = resp.MustText()
+			accepted, errAnswer := st.accept(resp, so)
+			if errAnswer != nil {
+				return st.stop(StopReasonError), errAnswer
+			}
+			if accepted {
+				return st.stop(StopReasonFinalAnswer), nil
+			}
+			continue
+		}
+
+		if stop, errTurn := a.toolTurn(ctx, st, budget, step, nil); stop {
+			return st.result, errTurn
+		}
+	}
+}
+
+// checkResponse reports whether resp is something the loop can act on:
+// an assistant text or a request of at least one tool call.
+func checkResponse(resp Message) error {
+	switch resp.Type() {
+	case MessageTypeAssistant:
+		if _, err := resp.Text(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		return nil
+	case MessageTypeToolRequest:
+		if len(resp.toolCallRequests) == 0 {
+			return fmt.Errorf("%w: tool request without calls", ErrInvalidResponse)
+		}
+		return nil
+	case MessageTypeSystem, MessageTypeUser, MessageTypeToolResponse:
+	}
+	return fmt.Errorf("%w: got a %s message", ErrInvalidResponse, resp.Type())
+}
+
+// toolTurn executes the calls requested by step.Response and appends the
+// request and its responses to history. It reports stop when the run has to
+// end, in which case st.result is already finalized. Decisions are nil for
+// a fresh turn and hold the caller's verdicts when resuming a suspended one.
+func (a *Agent) toolTurn(
+	ctx context.Context,
+	st *runState,
+	budget Budget,
+	step Step,
+	decisions map[string]ApprovalDecision,
+) (bool, error) {
+	resp := step.Response
+	tcRequests := resp.MustToolCallRequests()
+	if budget.MaxToolCalls > 0 && st.toolCalls+len(tcRequests) > budget.MaxToolCalls {
+		// the unanswered request is left out to keep the history continuable
+		st.result.Steps = append(st.result.Steps, step)
+		st.stop(StopReasonBudgetExceeded)
+		return true, newBudgetExceededError(BudgetLimitToolCalls, st.history)
+	}
+
+	if decisions == nil {
+		st.unknown = append(st.unknown, a.unknownTools(ctx, tcRequests)...)
+		if a.unknownToolLimit > 0 && len(st.unknown) > a.unknownToolLimit {
+			st.result.Steps = append(st.result.Steps, step)
+			st.stop(StopReasonError)
+			return true, &UnknownToolsError{Limit: a.unknownToolLimit, Calls: st.unknown}
+		}
+
+		if pending := a.pendingApproval(st, tcRequests); pending != nil {
+			st.history = append(st.history, resp)
+			st.result.Steps = append(st.result.Steps, step)
+			st.result.Pending = pending
+			st.stop(StopReasonPendingApproval)
+			return true, nil
+		}
+	}
+
+	tcRequests, denied := applyDecisions(tcRequests, decisions)
+	resp.toolCallRequests = tcRequests
+
+	records, errExec := a.executeTools(ctx, tcRequests, denied)
+	step.ToolCalls = records
+	st.result.Steps = append(st.result.Steps, step)
+	if errExec != nil {
+		_, err := st.fail(ctx, errExec)
+		return true, err
+	}
+
+	st.history = append(st.history, resp)
+	for _, rec := range records {
+		st.history = append(st.history, rec.message())
+		var toolErr *ToolError
+		if errors.As(rec.Err, &toolErr) {
+			st.result.ToolErrors = append(st.result.ToolErrors, *toolErr)
+		}
+	}
+	st.toolCalls += len(tcRequests)
+
+	return false, nil
+}
+
+// accept reports whether the final answer resp ends the run. A rejected
+// answer is followed in history by the message asking for a new one.
+func (st *runState) accept(resp Message, so sendOpts) (bool, error) {
+	if so.checkAnswer == nil {
+		return true, nil
+	}
+
+	retry, ok, err := so.checkAnswer(resp)
+	if err != nil || ok {
+		return true, err
+	}
+	st.history = append(st.history, retry)
+	return false, nil
+}
+
+func (st *runState) addUsage(m Message) {
+	st.result.Usage = st.result.Usage.Add(m.Usage())
+	st.result.UsageByModel.add(m)
+}
+
+func (st *runState) checkBudget(ctx context.Context, b Budget) error {
+	// an llm that ignores the context must not outlive the timeout
+	if timedOut(ctx) {
+		return newBudgetExceededError(BudgetLimitTimeout, st.history)
+	}
+	if b.MaxIterations > 0 && st.iterations >= b.MaxIterations {
+		return newBudgetExceededError(BudgetLimitIterations, st.history)
+	}
+	if b.MaxTokens > 0 && st.result.Usage.TotalTokens >= b.MaxTokens {
+		return newBudgetExceededError(BudgetLimitTokens, st.history)
+	}
+	return nil
+}
+
+// fail stops the run on err, reporting it as an exceeded budget when
+// the run's own timeout caused it.
+func (st *runState) fail(ctx context.Context, err error) (RunResult, error) {
+	if timedOut(ctx) {
+		return st.stop(StopReasonBudgetExceeded), newBudgetExceededError(BudgetLimitTimeout, st.history)
+	}
+	return st.stop(StopReasonError), err
+}
+
+func (st *runState) stop(reason StopReason) RunResult {
+	st.result.History = st.history
+	st.result.StopReason = reason
+	return st.result
+}
+
+// executeTools runs the requested calls with at most a.toolConcurrency in flight
+// and returns their records in request order. Failures reported to the llm are
+// kept in the records; an aborting failure cancels the context of calls that
+// are still running. On error only the records of calls that ran are returned.
+func (a *Agent) executeTools(
+	ctx context.Context,
+	reqs []ToolCallRequest,
+	denied map[string]string,
+) ([]ToolCallRecord, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := a.toolConcurrency
+	if limit <= 0 || limit > len(reqs) {
+		limit = len(reqs)
+	}
+
+	var (
+		records  = make([]ToolCallRecord, len(reqs))
+		ran      = make([]bool, len(reqs))
+		sem      = make(chan struct{}, limit)
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i, req := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// select picks at random when both are ready
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			rec, err := a.executeTool(ctx, req, denied)
+			if err != nil {
+				fail(err)
+				return
+			}
+			records[i] = rec
+			ran[i] = true
+			if rec.Err != nil && a.toolErrorPolicy.Action == ToolErrorAbort {
+				fail(rec.Err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		var done []ToolCallRecord
+		for i, rec := range records {
+			if ran[i] {
+				done = append(done, rec)
+			}
+		}
+		return done, firstErr
+	}
+
+	return records, nil
+}
+
+// executeTool always returns a record with the result to put into history;
+// on failure the result carries the error text. Calls listed in denied are
+// answered with the denial instead of being executed. The returned error
+// comes from interceptors and aborts the run.
+func (a *Agent) executeTool(
+	ctx context.Context,
+	req ToolCallRequest,
+	denied map[string]string,
+) (ToolCallRecord, error) {
+	if reason, ok := denied[req.Call.ID]; ok {
+		return a.afterToolCall(ctx, ToolCallRecord{Request: req, Result: reason})
+	}
+
+	req, shortCircuit, err := a.beforeToolCall(ctx, req)
+	if err != nil {
+		return ToolCallRecord{}, err
+	}
+
+	rec := ToolCallRecord{Request: req}
+	switch tcExecutable, ok := a.toolRegistry[req.Call.Name]; {
+	case shortCircuit != nil:
+		rec.Result = *shortCircuit
+	case !ok:
+		rec.Result = a.toolNotFoundResult(req.Call.Name)
+	default:
+		start := time.Now()
+		content, toolErr := a.executeWithRetries(ctx, tcExecutable, req)
+		rec.Duration = time.Since(start)
+		rec.Result = content
+		if toolErr != nil {
+			rec.Result = toolErrorResult(toolErr)
+			rec.Err = toolErr
+		}
+	}
+
+	return a.afterToolCall(ctx, rec)
+}
@@ -0,0 +1,231 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+type stepArgs struct {
+	Name string `json:"name"`
+}
+
+func TestConcurrentToolResultsKeepRequestOrder(t *testing.T) {
+	// "first" finishes last and "last" first, so completion order is reversed
+	lastDone := make(chan struct{})
+	step := aiagent.MustNewDerivedTool("step", "runs a step",
+		func(_ context.Context, args stepArgs) (string, error) {
+			switch args.Name {
+			case "first":
+				<-lastDone
+			case "last":
+				close(lastDone)
+			}
+			return args.Name + " done", nil
+		})
+
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTools(
+			aiagenttest.ToolCall("step", stepArgs{Name: "first"}),
+			aiagenttest.ToolCall("step", stepArgs{Name: "middle"}),
+			aiagenttest.ToolCall("step", stepArgs{Name: "last"}),
+		),
+		aiagenttest.Reply("all done").Expecting(aiagenttest.HistoryLen(5)),
+	)
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(step), aiagent.WithToolConcurrency(3))
+
+	res, err := agent.SendMessage(context.Background(), "run the steps")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	want := []string{"first done", "middle done", "last done"}
+	calls := aiagenttest.ToolCalls(res)
+	if len(calls) != len(want) {
+		t.Fatalf("got %d tool calls, want %d", len(calls), len(want))
+	}
+	history := llm.Calls()[1]
+	for i, result := range want {
+		if calls[i].Result != result {
+			t.Errorf("record %d result = %q, want %q", i, calls[i].Result, result)
+		}
+		resp := history[2+i].MustToolCallResponse()
+		if resp.Result != result || resp.Call.ID != calls[i].Request.Call.ID {
+			t.Errorf("history response %d = %s %q, want %s %q",
+				i, resp.Call.ID, resp.Result, calls[i].Request.Call.ID, result)
+		}
+	}
+}
+
+func TestAbortCancelsRunningToolCalls(t *testing.T) {
+	errBoom := errors.New("boom")
+	cancelled := make(chan error, 1)
+
+	slow := aiagent.MustNewDerivedTool("slow", "waits for cancellation",
+		func(ctx context.Context, _ struct{}) (string, error) {
+			select {
+			case <-ctx.Done():
+				cancelled <- ctx.Err()
+				return "", ctx.Err()
+			case <-time.After(5 * time.Second):
+				cancelled <- nil
+				return "finished", nil
+			}
+		})
+	failing := aiagent.MustNewDerivedTool("fail", "fails at once",
+		func(context.Context, struct{}) (string, error) {
+			return "", errBoom
+		})
+
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTools(
+		aiagenttest.ToolCall("slow", struct{}{}),
+		aiagenttest.ToolCall("fail", struct{}{}),
+	))
+	agent := aiagent.NewAgent(llm,
+		aiagent.WithTools(slow, failing),
+		aiagent.WithToolConcurrency(2),
+		aiagent.WithToolErrorPolicy(aiagent.ToolErrorPolicy{Action: aiagent.ToolErrorAbort}),
+	)
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v, want %v", err, errBoom)
+	}
+	if res.StopReason != aiagent.StopReasonError {
+		t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonError)
+	}
+	if got := <-cancelled; !errors.Is(got, context.Canceled) {
+		t.Errorf("slow call ended with %v, want %v", got, context.Canceled)
+	}
+	llm.AssertDone()
+}
+
+func TestResumeAfterApproval(t *testing.T) {
+	type deleteArgs struct {
+		Path string `json:"path"`
+	}
+
+	tests := []struct {
+		name     string
+		decision func(callID string) aiagent.ApprovalDecision
+		deleted  []string
+		result   string
+	}{
+		{
+			name:     "approve",
+			decision: aiagent.Approve,
+			deleted:  []string{"a.txt"},
+			result:   "deleted a.txt",
+		},
+		{
+			name: "edit",
+			decision: func(callID string) aiagent.ApprovalDecision {
+				return aiagent.EditArgs(callID, []byte(`{"path":"b.txt"}`))
+			},
+			deleted: []string{"b.txt"},
+			result:  "deleted b.txt",
+		},
+		{
+			name: "deny",
+			decision: func(callID string) aiagent.ApprovalDecision {
+				return aiagent.Deny(callID, "keep it")
+			},
+			result: "error: the call was denied by the user: keep it",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			del := aiagent.RequireApproval(aiagent.MustNewDerivedTool("delete", "deletes a file",
+				func(_ context.Context, args deleteArgs) (string, error) {
+					deleted = append(deleted, args.Path)
+					return "deleted " + args.Path, nil
+				}))
+
+			llm := aiagenttest.NewFakeLLM(t,
+				aiagenttest.CallTool("delete", deleteArgs{Path: "a.txt"}),
+				aiagenttest.Reply("ok").Expecting(aiagenttest.ToolResult("delete", tt.result)),
+			)
+			agent := aiagent.NewAgent(llm, aiagent.WithTool(del))
+
+			res, err := agent.SendMessage(context.Background(), "delete a.txt")
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if res.StopReason != aiagent.StopReasonPendingApproval || res.Pending == nil {
+				t.Fatalf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonPendingApproval)
+			}
+			if len(deleted) != 0 {
+				t.Fatalf("tool ran before approval: %q", deleted)
+			}
+			calls := res.Pending.Calls
+			if len(calls) != 1 || !calls[0].NeedsApproval {
+				t.Fatalf("pending calls = %+v, want one call needing approval", calls)
+			}
+
+			decisions := []aiagent.ApprovalDecision{tt.decision(calls[0].Request.Call.ID)}
+			res, err = agent.Resume(context.Background(), res.History, *res.Pending, decisions)
+			if err != nil {
+				t.Fatalf("resume: %v", err)
+			}
+			if res.StopReason != aiagent.StopReasonFinalAnswer || res.Text != "ok" {
+				t.Errorf("resume ended with %s %q, want %s %q",
+					res.StopReason, res.Text, aiagent.StopReasonFinalAnswer, "ok")
+			}
+			if !slices.Equal(deleted, tt.deleted) {
+				t.Errorf("deleted %q, want %q", deleted, tt.deleted)
+			}
+			llm.AssertDone()
+		})
+	}
+}
+
+func TestCancelBeforeToolDispatch(t *testing.T) {
+	// the select dispatching calls picks at random, so repeat to hit both cases
+	for range 50 {
+		ctx, cancel := context.WithCancel(context.Background())
+		step := aiagent.MustNewDerivedTool("step", "runs a step",
+			func(context.Context, stepArgs) (string, error) {
+				return "done", nil
+			})
+		llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTools(
+			aiagenttest.ToolCall("step", stepArgs{Name: "a"}),
+			aiagenttest.ToolCall("step", stepArgs{Name: "b"}),
+			aiagenttest.ToolCall("step", stepArgs{Name: "c"}),
+		))
+		agent := aiagent.NewAgent(llm,
+			aiagent.WithTool(step),
+			aiagent.WithToolConcurrency(0),
+			aiagent.WithInterceptor(aiagent.Interceptor{
+				AfterLLMCall: func(_ context.Context, resp aiagent.Message) (aiagent.Message, error) {
+					cancel()
+					return resp, nil
+				},
+			}),
+		)
+
+		res, err := agent.SendMessage(ctx, "go")
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want %v", err, context.Canceled)
+		}
+		if res.StopReason != aiagent.StopReasonError {
+			t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonError)
+		}
+		for _, m := range res.History {
+			if m.IsToolCallResponse() {
+				t.Fatalf("history has a tool response after cancellation: %v", m.MustToolCallResponse())
+			}
+		}
+		for _, rec := range aiagenttest.ToolCalls(res) {
+			if rec.Request.Call.ID == "" {
+				t.Fatalf("record of a call that never ran: %+v", rec)
+			}
+		}
+	}
+}
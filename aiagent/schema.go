@@ -0,0 +1,369 @@
+package aiagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// DeriveParams derives the params of a tool or structured output from the
+// struct T, following the field names and types of encoding/json. A field is
+// required unless it is a pointer or tagged omitempty. Struct tags add to
+// the schema:
+//
+//	description:"..."  describes the field
+//	enum:"a,b,c"       lists the allowed values
+//	min:"0" max:"10"   bound a number
+//
+// On slices the enum and bounds apply to the elements.
+func DeriveParams[T any]() ([]Param, error) {
+	return paramsOf(reflect.TypeFor[T]())
+}
+
+func paramsOf(typ reflect.Type) ([]Param, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrUnsupportedType, typ)
+	}
+
+	return fieldParams(typ, nil)
+}
+
+func fieldParams(typ reflect.Type, visiting []reflect.Type) ([]Param, error) {
+	if slices.Contains(visiting, typ) {
+		return nil, fmt.Errorf("%w: %s is recursive", ErrUnsupportedType, typ)
+	}
+	visiting = append(visiting, typ)
+
+	params := make([]Param, 0, typ.NumField())
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		name, ok := jsonFieldName(f)
+		if !ok {
+			continue
+		}
+
+		// untagged embedded structs are flattened, like encoding/json does
+		if f.Anonymous && f.Tag.Get("json") == "" && indirect(f.Type).Kind() == reflect.Struct {
+			embedded, err := fieldParams(indirect(f.Type), visiting)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, embedded...)
+			continue
+		}
+		if !isExported(f) {
+			continue
+		}
+
+		p, err := paramOf(f.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		p.Name = name
+		p.Required = f.Type.Kind() != reflect.Pointer && !hasJSONOption(f, "omitempty")
+		if err = applyTags(&p, f); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		params = append(params, p)
+	}
+
+	return params, nil
+}
+
+func paramOf(typ reflect.Type, visiting []reflect.Type) (Param, error) {
+	typ = indirect(typ)
+	switch typ {
+	case reflect.TypeFor[time.Time]():
+		return Param{Type: ParamTypeString}, nil
+	case reflect.TypeFor[json.RawMessage]():
+		// raw JSON holds a value of any type
+		return Param{Type: ParamTypeAny}, nil
+	}
+
+	switch typ.Kind() { //nolint:exhaustive // the other kinds have no JSON form
+	case reflect.String:
+		return Param{Type: ParamTypeString}, nil
+	case reflect.Bool:
+		return Param{Type: ParamTypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Param{Type: ParamTypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return Param{Type: ParamTypeNumber}, nil
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return Param{Type: ParamTypeString}, nil
+		}
+		items, err := paramOf(typ.Elem(), visiting)
+		if err != nil {
+			return Param{}, err
+		}
+		return Param{Type: ParamTypeArray, Items: &items}, nil
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return Param{}, fmt.Errorf("%w: %s has non-string keys", ErrUnsupportedType, typ)
+		}
+		values, err := paramOf(typ.Elem(), visiting)
+		if err != nil {
+			return Param{}, err
+		}
+		return Param{Type: ParamTypeObject, Values: &values}, nil
+	case reflect.Struct:
+		fields, err := fieldParams(typ, visiting)
+		if err != nil {
+			return Param{}, err
+		}
+		props := make(map[string]Param, len(fields))
+		for _, p := range fields {
+			props[p.Name] = p
+		}
+		return Param{Type: ParamTypeObject, Properties: props}, nil
+	default:
+		return Param{}, fmt.Errorf("%w: %s", ErrUnsupportedType, typ)
+	}
+}
+
+func applyTags(p *Param, f reflect.StructField) error {
+	p.Description = f.Tag.Get("description")
+
+	// enum and bounds describe the elements of a slice
+	target := p
+	for target.Type == ParamTypeArray && target.Items != nil {
+		target = target.Items
+	}
+	elem := indirect(f.Type)
+	for elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+		elem = indirect(elem.Elem())
+	}
+
+	if tag, ok := f.Tag.Lookup("enum"); ok {
+		for _, s := range strings.Split(tag, ",") {
+			v, err := parseTagValue(strings.TrimSpace(s), elem)
+			if err != nil {
+				return fmt.Errorf("enum: %w", err)
+			}
+			target.Enum = append(target.Enum, v)
+		}
+	}
+
+	for _, b := range []struct {
+		tag   string
+		bound **float64
+	}{
+		{"min", &target.Minimum},
+		{"max", &target.Maximum},
+	} {
+		tag, ok := f.Tag.Lookup(b.tag)
+		if !ok {
+			continue
+		}
+		if target.Type != ParamTypeInteger && target.Type != ParamTypeNumber {
+			return fmt.Errorf("%s: %w: bound on a non-numeric field", b.tag, ErrUnsupportedType)
+		}
+		v, err := strconv.ParseFloat(tag, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.tag, err)
+		}
+		*b.bound = &v
+	}
+
+	return nil
+}
+
+// parseTagValue converts a value written in a struct tag to the JSON type
+// of a field of type typ.
+func parseTagValue(s string, typ reflect.Type) (any, error) {
+	switch typ.Kind() { //nolint:exhaustive // enums of other kinds are rejected
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, typ.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, typ.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, typ.Bits())
+	default:
+		return nil, fmt.Errorf("%w: enum of %s", ErrUnsupportedType, typ)
+	}
+}
+
+func indirect(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+func hasJSONOption(f reflect.StructField, option string) bool {
+	parts := strings.Split(f.Tag.Get("json"), ",")
+	return slices.Contains(parts[1:], option)
+}
+
+// validateParams checks a JSON object against params: required properties
+// must be present, and present ones must have the declared type and be one
+// of the enum values, if any.
+func validateParams(raw []byte, params []Param) error {
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return fmt.Errorf("not a JSON object: %w", err)
+	}
+
+	return validateObject("", obj, params)
+}
+
+func validateObject(path string, obj map[string]any, params []Param) error {
+	for _, p := range params {
+		v, ok := obj[p.Name]
+		if !ok || v == nil {
+			if p.Required {
+				return fmt.Errorf("%s: required property is missing", joinPath(path, p.Name))
+			}
+			continue
+		}
+		if err := validateValue(joinPath(path, p.Name), v, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(path string, v any, p Param) error {
+	if !hasParamType(v, p.Type) {
+		return fmt.Errorf("%s: want %s, got %s", path, paramTypeName(p.Type), jsonTypeName(v))
+	}
+	if len(p.Enum) > 0 && !inEnum(v, p.Enum) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, p.Enum)
+	}
+	if n, ok := v.(float64); ok {
+		if p.Minimum != nil && n < *p.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, n, *p.Minimum)
+		}
+		if p.Maximum != nil && n > *p.Maximum {
+			return fmt.Errorf("%s: %v is greater than %v", path, n, *p.Maximum)
+		}
+	}
+
+	switch p.Type { //nolint:exhaustive // scalars are fully checked above
+	case ParamTypeArray:
+		if p.Items == nil {
+			return nil
+		}
+		for i, item := range v.([]any) { //nolint:errcheck // checked by hasParamType
+			if err := validateValue(fmt.Sprintf("%s[%d]", path, i), item, *p.Items); err != nil {
+				return err
+			}
+		}
+	case ParamTypeObject:
+		obj := v.(map[string]any) //nolint:errcheck // checked by hasParamType
+		if p.Values != nil {
+			for key, value := range obj {
+				if err := validateValue(joinPath(path, key), value, *p.Values); err != nil {
+					return err
+				}
+			}
+		}
+		props := make([]Param, 0, len(p.Properties))
+		for name, sub := range p.Properties {
+			sub.Name = name
+			props = append(props, sub)
+		}
+		return validateObject(path, obj, props)
+	}
+	return nil
+}
+
+func hasParamType(v any, pt ParamType) bool {
+	switch pt {
+	case ParamTypeString:
+		_, ok := v.(string)
+		return ok
+	case ParamTypeInteger:
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case ParamTypeNumber:
+		_, ok := v.(float64)
+		return ok
+	case ParamTypeBoolean:
+		_, ok := v.(bool)
+		return ok
+	case ParamTypeObject:
+		_, ok := v.(map[string]any)
+		return ok
+	case ParamTypeArray:
+		_, ok := v.([]any)
+		return ok
+	case ParamTypeAny:
+		return true
+	}
+	return false
+}
+
+// inEnum compares values in JSON form, so that 1 matches 1.0.
+func inEnum(v any, enum []any) bool {
+	a, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	for _, e := range enum {
+		if b, errB := json.Marshal(e); errB == nil && string(a) == string(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func paramTypeName(pt ParamType) string {
+	switch pt {
+	case ParamTypeString:
+		return "string"
+	case ParamTypeInteger:
+		return "integer"
+	case ParamTypeNumber:
+		return "number"
+	case ParamTypeBoolean:
+		return "boolean"
+	case ParamTypeObject:
+		return "object"
+	case ParamTypeArray:
+		return "array"
+	case ParamTypeAny:
+		return "any"
+	}
+	return fmt.Sprintf("unknown_param_type(%d)", pt)
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return "null"
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,203 @@
+package aiagent_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+type Base struct {
+	ID string `json:"id"`
+}
+
+type address struct {
+	Street string `json:"street"`
+	Zip    *int   `json:"zip"`
+}
+
+type order struct {
+	Base
+
+	Customer string            `json:"customer" description:"who ordered"`
+	Note     string            // untagged, named like encoding/json does
+	Status   string            `json:"status" enum:"open,closed"`
+	Qty      int               `json:"qty" min:"1" max:"10"`
+	Weights  []float64         `json:"weights,omitempty" min:"0"`
+	Sizes    []int             `json:"sizes" enum:"1,2,3"`
+	Gift     *bool             `json:"gift"`
+	Address  address           `json:"address"`
+	Labels   map[string]string `json:"labels"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Photo    []byte            `json:"photo,omitempty"`
+	Placed   time.Time         `json:"placed"`
+	Skipped  string            `json:"-"`
+	internal string
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestDeriveParams(t *testing.T) {
+	params, err := aiagent.DeriveParams[order]()
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+
+	str := aiagent.Param{Type: aiagent.ParamTypeString}
+	want := []aiagent.Param{
+		{Name: "id", Type: aiagent.ParamTypeString, Required: true},
+		{Name: "customer", Type: aiagent.ParamTypeString, Required: true, Description: "who ordered"},
+		{Name: "Note", Type: aiagent.ParamTypeString, Required: true},
+		{Name: "status", Type: aiagent.ParamTypeString, Required: true, Enum: []any{"open", "closed"}},
+		{Name: "qty", Type: aiagent.ParamTypeInteger, Required: true, Minimum: ptr(1), Maximum: ptr(10)},
+		{Name: "weights", Type: aiagent.ParamTypeArray,
+			Items: &aiagent.Param{Type: aiagent.ParamTypeNumber, Minimum: ptr(0)}},
+		{Name: "sizes", Type: aiagent.ParamTypeArray, Required: true,
+			Items: &aiagent.Param{Type: aiagent.ParamTypeInteger, Enum: []any{int64(1), int64(2), int64(3)}}},
+		{Name: "gift", Type: aiagent.ParamTypeBoolean},
+		{Name: "address", Type: aiagent.ParamTypeObject, Required: true, Properties: map[string]aiagent.Param{
+			"street": {Name: "street", Type: aiagent.ParamTypeString, Required: true},
+			"zip":    {Name: "zip", Type: aiagent.ParamTypeInteger},
+		}},
+		{Name: "labels", Type: aiagent.ParamTypeObject, Required: true, Values: &str},
+		{Name: "raw", Type: aiagent.ParamTypeAny},
+		{Name: "photo", Type: aiagent.ParamTypeString},
+		{Name: "placed", Type: aiagent.ParamTypeString, Required: true},
+	}
+	if len(params) != len(want) {
+		t.Fatalf("got %d params, want %d: %+v", len(params), len(want), params)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(params[i], want[i]) {
+			t.Errorf("param %d = %+v, want %+v", i, params[i], want[i])
+		}
+	}
+}
+
+func TestDeriveParamsUnsupported(t *testing.T) {
+	type recursive struct {
+		Next *recursive `json:"next"`
+	}
+	type channel struct {
+		C chan int `json:"c"`
+	}
+	type intKeys struct {
+		M map[int]string `json:"m"`
+	}
+	type boundOnString struct {
+		S string `json:"s" min:"1"`
+	}
+	type enumOfStruct struct {
+		A address `json:"a" enum:"x"`
+	}
+
+	tests := []struct {
+		name   string
+		derive func() ([]aiagent.Param, error)
+	}{
+		{name: "not a struct", derive: aiagent.DeriveParams[string]},
+		{name: "recursive", derive: aiagent.DeriveParams[recursive]},
+		{name: "channel", derive: aiagent.DeriveParams[channel]},
+		{name: "int keys", derive: aiagent.DeriveParams[intKeys]},
+		{name: "bound on string", derive: aiagent.DeriveParams[boundOnString]},
+		{name: "enum of struct", derive: aiagent.DeriveParams[enumOfStruct]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.derive(); !errors.Is(err, aiagent.ErrUnsupportedType) {
+				t.Errorf("err = %v, want %v", err, aiagent.ErrUnsupportedType)
+			}
+		})
+	}
+}
+
+func TestDeriveParamsBadTag(t *testing.T) {
+	type badEnum struct {
+		N int `json:"n" enum:"one"`
+	}
+	if _, err := aiagent.DeriveParams[badEnum](); err == nil || !strings.Contains(err.Error(), "field N") {
+		t.Errorf("err = %v, want an enum error of field N", err)
+	}
+}
+
+func TestDecodeTypedValidation(t *testing.T) {
+	valid := `{"id": "o1", "customer": "ann", "Note": "", "status": "open", "qty": 2, "sizes": [1],
+		"address": {"street": "main"}, "labels": {}, "placed": "2024-01-02T03:04:05Z"}`
+
+	tests := []struct {
+		name string
+		text string
+		// err is a part of the error, empty for a valid answer
+		err string
+	}{
+		{name: "valid", text: valid},
+		{name: "code fence", text: "```json\n" + valid + "\n```"},
+		{name: "any raw value", text: strings.Replace(valid, `"qty"`, `"raw": [1, {"a": null}], "qty"`, 1)},
+		{name: "not an object", text: `[1]`, err: "not a JSON object"},
+		{name: "missing", text: strings.Replace(valid, `"customer": "ann", `, "", 1), err: "customer: required"},
+		{name: "untagged lowercase", text: strings.Replace(valid, `"Note"`, `"note"`, 1), err: "Note: required"},
+		{name: "wrong type", text: strings.Replace(valid, `"qty": 2`, `"qty": "2"`, 1), err: "qty: want integer"},
+		{name: "fraction", text: strings.Replace(valid, `"qty": 2`, `"qty": 2.5`, 1), err: "qty: want integer"},
+		{name: "enum", text: strings.Replace(valid, `"open"`, `"lost"`, 1), err: "status: lost is not one of"},
+		{name: "minimum", text: strings.Replace(valid, `"qty": 2`, `"qty": 0`, 1), err: "qty: 0 is less than 1"},
+		{name: "maximum", text: strings.Replace(valid, `"qty": 2`, `"qty": 11`, 1), err: "greater than 10"},
+		{name: "item", text: strings.Replace(valid, `[1]`, `[1, 4]`, 1), err: "sizes[1]: 4 is not one of"},
+		{name: "nested", text: strings.Replace(valid, `"street": "main"`, `"zip": 1`, 1), err: "address.street"},
+		{name: "map value", text: strings.Replace(valid, `"labels": {}`, `"labels": {"a": 1}`, 1), err: "labels.a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := aiagent.DecodeTyped[order](tt.text)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("decode: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeTypedKeepsRawJSON(t *testing.T) {
+	type wrapped struct {
+		Raw json.RawMessage `json:"raw"`
+	}
+
+	got, err := aiagent.DecodeTyped[wrapped](`{"raw": {"a": [1, 2]}}`)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(got.Raw) != `{"a": [1, 2]}` {
+		t.Errorf("raw = %s, want the object as sent", got.Raw)
+	}
+}
+
+type span struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (s *span) Validate() error {
+	if s.From > s.To {
+		return errors.New("from is after to")
+	}
+	return nil
+}
+
+func TestDecodeTypedValidator(t *testing.T) {
+	if _, err := aiagent.DecodeTyped[span](`{"from": 1, "to": 2}`); err != nil {
+		t.Errorf("decode: %v", err)
+	}
+	if _, err := aiagent.DecodeTyped[span](`{"from": 2, "to": 1}`); err == nil || err.Error() != "from is after to" {
+		t.Errorf("err = %v, want the Validate error", err)
+	}
+}
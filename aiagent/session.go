@@ -0,0 +1,249 @@
+package aiagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var ErrNoPendingApproval = errors.New("session has no run pending approval")
+
+// Session keeps the conversation with an agent across turns. It is safe for
+// concurrent use; turns are serialized, so calls block while a turn is running.
+type Session struct {
+	agent *Agent
+
+	store ConversationStore
+	id    string
+
+	mu           sync.Mutex
+	systemPrompt string
+	turns        []Message
+	pending      *PendingApproval
+	usage        UsageByModel
+}
+
+type SessionOption func(*Session)
+
+func WithSessionSystemPrompt(p string) SessionOption {
+	return func(s *Session) {
+		s.systemPrompt = p
+	}
+}
+
+// WithSessionHistory seeds the session with earlier turns, counting
+// the usage they carry. System messages belong to WithSessionSystemPrompt instead.
+func WithSessionHistory(history []Message) SessionOption {
+	return func(s *Session) {
+		s.turns = slices.Clone(history)
+		for _, m := range history {
+			s.usage.add(m)
+		}
+	}
+}
+
+// WithSessionStore persists the session's turns in store under id.
+// Use Agent.LoadSession to continue a stored session.
+func WithSessionStore(store ConversationStore, id string) SessionOption {
+	return func(s *Session) {
+		s.store = store
+		s.id = id
+	}
+}
+
+func (a *Agent) NewSession(opts ...SessionOption) *Session {
+	s := &Session{agent: a, usage: make(UsageByModel)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// LoadSession restores the session stored under id, or starts it empty
+// if the store does not know it yet. A stored history that ends with an
+// unanswered tool request is pending approval again, see Session.Resume.
+func (a *Agent) LoadSession(
+	ctx context.Context,
+	store ConversationStore,
+	id string,
+	opts ...SessionOption,
+) (*Session, error) {
+	history, err := store.Load(ctx, id)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, fmt.Errorf("load session %s: %w", id, err)
+	}
+
+	opts = append(opts, WithSessionHistory(history), WithSessionStore(store, id))
+	s := a.NewSession(opts...)
+	s.pending = a.restorePending(s.turns)
+
+	return s, nil
+}
+
+// ID returns the id the session is stored under, if any.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Send adds userMessage to the conversation and runs the agent on it.
+// Prompts from WithSystemPromptAppend apply to this turn only.
+// The turn is kept when the run finishes, exceeds its budget or is suspended
+// for approval; on other errors the conversation is left unchanged.
+func (s *Session) Send(ctx context.Context, userMessage string, opts ...SendOption) (RunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending != nil {
+		return RunResult{}, ErrPendingApproval
+	}
+
+	so := newSendOpts(opts)
+	prompt := s.prompt(so.appendSystemPrompt)
+	history := s.history(prompt)
+	history = append(history, NewUserMessage(userMessage))
+
+	result, err := s.agent.run(ctx, newRunState(history), so, nil)
+	if errCommit := s.commit(ctx, result, prompt != ""); errCommit != nil {
+		return result, errCommit
+	}
+
+	return result, err
+}
+
+// Resume continues the turn suspended for approval, see Agent.Resume.
+func (s *Session) Resume(ctx context.Context, decisions []ApprovalDecision, opts ...SendOption) (RunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		return RunResult{}, ErrNoPendingApproval
+	}
+
+	prompt := s.prompt(newSendOpts(opts).appendSystemPrompt)
+	result, err := s.agent.Resume(ctx, s.history(prompt), *s.pending, decisions, opts...)
+	if errCommit := s.commit(ctx, result, prompt != ""); errCommit != nil {
+		return result, errCommit
+	}
+
+	return result, err
+}
+
+// Pending returns the approval the session waits for, if any.
+func (s *Session) Pending() (PendingApproval, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		return PendingApproval{}, false
+	}
+	return *s.pending, true
+}
+
+// Usage returns the token usage of all runs of the session per model,
+// including runs that failed.
+func (s *Session) Usage() UsageByModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.usage)
+}
+
+// Transcript returns the conversation including the system prompt.
+func (s *Session) Transcript() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.history(s.prompt(nil))
+}
+
+// Reset drops all turns, keeping the system prompt.
+func (s *Session) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.turns = nil
+	s.pending = nil
+
+	if s.store == nil {
+		return nil
+	}
+	if err := s.store.Delete(ctx, s.id); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return fmt.Errorf("delete session %s: %w", s.id, err)
+	}
+	return nil
+}
+
+// SetSystemPrompt replaces the system prompt for the following turns.
+// An empty prompt removes it.
+func (s *Session) SetSystemPrompt(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.systemPrompt = p
+}
+
+func (s *Session) prompt(appends []string) string {
+	return s.agent.newSystemPrompt(append([]string{s.systemPrompt}, appends...))
+}
+
+// history returns the turns behind prompt, if there is one.
+func (s *Session) history(prompt string) []Message {
+	if prompt == "" {
+		return slices.Clone(s.turns)
+	}
+
+	history := make([]Message, 0, len(s.turns)+1)
+	history = append(history, NewSystemMessage(prompt))
+	return append(history, s.turns...)
+}
+
+// commit stores the outcome of a run unless it failed, in which case the
+// session stays as it was before the run. New turns are appended to the store;
+// if the run changed any of the stored turns, e.g. by compaction, an interceptor
+// or edited tool arguments, the stored session is replaced. prompted tells whether the run started with the session's
+// system prompt, which is not one of the turns.
+func (s *Session) commit(ctx context.Context, result RunResult, prompted bool) error {
+	for model, u := range result.UsageByModel {
+		s.usage[model] = s.usage[model].Add(u)
+	}
+	if result.StopReason == StopReasonError || len(result.History) == 0 {
+		return nil
+	}
+
+	turns := result.History
+	if prompted && turns[0].Type() == MessageTypeSystem {
+		turns = turns[1:]
+	}
+	prev := s.turns
+	s.turns = slices.Clone(turns)
+	s.pending = result.Pending
+
+	if s.store == nil {
+		return nil
+	}
+	if rewritten(prev, turns) {
+		if err := s.store.Replace(ctx, s.id, turns...); err != nil {
+			return fmt.Errorf("replace session %s: %w", s.id, err)
+		}
+		return nil
+	}
+	if err := s.store.Append(ctx, s.id, turns[len(prev):]...); err != nil {
+		return fmt.Errorf("append session %s: %w", s.id, err)
+	}
+	return nil
+}
+
+// rewritten reports whether turns no longer start with the stored prev.
+func rewritten(prev, turns []Message) bool {
+	if len(turns) < len(prev) {
+		return true
+	}
+	return !slices.EqualFunc(prev, turns[:len(prev)], func(a, b Message) bool {
+		return reflect.DeepEqual(a, b)
+	})
+}
@@ -0,0 +1,81 @@
+package aiagent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ConversationStore persists session histories. Load and Delete return
+// ErrSessionNotFound for unknown session ids.
+type ConversationStore interface {
+	Load(ctx context.Context, sessionID string) ([]Message, error)
+	Append(ctx context.Context, sessionID string, msgs ...Message) error
+	// Replace swaps the whole history of the session for msgs at once,
+	// so a failure leaves either the old or the new history.
+	Replace(ctx context.Context, sessionID string, msgs ...Message) error
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, sessionID string) error
+}
+
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string][]Message
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string][]Message)}
+}
+
+func (s *MemoryStore) Load(_ context.Context, sessionID string) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msgs, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return slices.Clone(msgs), nil
+}
+
+func (s *MemoryStore) Append(_ context.Context, sessionID string, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sessionID] = append(s.sessions[sessionID], msgs...)
+	return nil
+}
+
+func (s *MemoryStore) Replace(_ context.Context, sessionID string, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sessionID] = slices.Clone(msgs)
+	return nil
+}
+
+func (s *MemoryStore) List(context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[sessionID]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(s.sessions, sessionID)
+	return nil
+}
@@ -0,0 +1,275 @@
+package aiagent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const fileStoreExt = ".jsonl"
+
+// FileStore keeps every session in its own JSONL file inside a directory,
+// one message per line. Appends are fsynced and files are locked, so several
+// processes may share the directory.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Load(_ context.Context, sessionID string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.openLocked(sessionID, os.O_RDONLY, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closeLocked(f)
+
+	return readMessages(f)
+}
+
+func (s *FileStore) Append(_ context.Context, sessionID string, msgs ...Message) error {
+	data, err := encodeMessages(msgs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.openLocked(sessionID, os.O_CREATE|os.O_APPEND|os.O_RDWR, true)
+	if err != nil {
+		return err
+	}
+	defer closeLocked(f)
+
+	if err = trimTornTail(f); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("sync session file: %w", err)
+	}
+
+	return nil
+}
+
+// Replace writes msgs to a temporary file and renames it over the session
+// file, holding the lock of the old one so no append goes missing.
+func (s *FileStore) Replace(_ context.Context, sessionID string, msgs ...Message) error {
+	data, err := encodeMessages(msgs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, ".replace-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp session file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("write temp session file: %w", err)
+	}
+
+	f, err := s.openLocked(sessionID, os.O_CREATE|os.O_RDWR, true)
+	if err != nil {
+		return err
+	}
+	defer closeLocked(f)
+
+	if err = os.Rename(tmp.Name(), s.path(sessionID)); err != nil {
+		return fmt.Errorf("replace session file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) List(context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read store dir: %w", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), fileStoreExt)
+		if e.IsDir() || !ok {
+			continue
+		}
+		id, errUnescape := url.PathUnescape(name)
+		if errUnescape != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.openLocked(sessionID, os.O_RDONLY, true)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer closeLocked(f)
+
+	if err = os.Remove(s.path(sessionID)); err != nil {
+		return fmt.Errorf("remove session file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) path(sessionID string) string {
+	return filepath.Join(s.dir, url.PathEscape(sessionID)+fileStoreExt)
+}
+
+// openLocked opens and locks the session file. A file that another process
+// replaced or removed while waiting for the lock is no longer the session's,
+// so it is opened again.
+func (s *FileStore) openLocked(sessionID string, flag int, exclusive bool) (*os.File, error) {
+	path := s.path(sessionID)
+	for {
+		f, err := os.OpenFile(path, flag, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open session file: %w", err)
+		}
+		if err = lockFile(f, exclusive); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+
+		locked, err := f.Stat()
+		if err != nil {
+			closeLocked(f)
+			return nil, fmt.Errorf("stat session file: %w", err)
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		closeLocked(f)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("stat session file: %w", err)
+		}
+	}
+}
+
+func closeLocked(f *os.File) {
+	unlockFile(f)
+	_ = f.Close()
+}
+
+func encodeMessages(msgs []Message) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		line, err := json.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("encode message: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// trimTornTail truncates f back to its last newline, dropping what an
+// interrupted append left behind, so new lines do not extend a partial one.
+func trimTornTail(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat session file: %w", err)
+	}
+
+	const chunkSize = 4096
+	var (
+		buf = make([]byte, chunkSize)
+		end = info.Size()
+	)
+	for end > 0 {
+		n := min(end, chunkSize)
+		if _, err = f.ReadAt(buf[:n], end-n); err != nil {
+			return fmt.Errorf("read session file: %w", err)
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = end - n + int64(i) + 1
+			break
+		}
+		end -= n
+	}
+	if end == info.Size() {
+		return nil
+	}
+
+	if err = f.Truncate(end); err != nil {
+		return fmt.Errorf("truncate session file: %w", err)
+	}
+	return nil
+}
+
+// readMessages decodes one message per line. A last line without a newline
+// is the remainder of an interrupted append and is ignored.
+func readMessages(r io.Reader) ([]Message, error) {
+	var (
+		msgs []Message
+		br   = bufio.NewReader(r)
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read session file: %w", err)
+		}
+
+		var m Message
+		if err = json.Unmarshal(line, &m); err != nil {
+			return nil, fmt.Errorf("decode message %d: %w", len(msgs), err)
+		}
+		msgs = append(msgs, m)
+	}
+}
@@ -0,0 +1,157 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+func newFileStore(t *testing.T, dir string) *aiagent.FileStore {
+	t.Helper()
+
+	store, err := aiagent.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	return store
+}
+
+func loadTexts(t *testing.T, store aiagent.ConversationStore, sessionID string) []string {
+	t.Helper()
+
+	msgs, err := store.Load(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	texts := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		texts = append(texts, m.MustText())
+	}
+	return texts
+}
+
+func TestFileStoreTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newFileStore(t, dir)
+
+	if err := store.Append(ctx, "s", aiagent.NewUserMessage("one")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	// an append interrupted halfway through its line
+	f, err := os.OpenFile(filepath.Join(dir, "s.jsonl"), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err = f.WriteString(`{"type":"user","te`); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if got := loadTexts(t, store, "s"); len(got) != 1 || got[0] != "one" {
+		t.Fatalf("loaded %q, want the complete line only", got)
+	}
+	if err = store.Append(ctx, "s", aiagent.NewUserMessage("two")); err != nil {
+		t.Fatalf("append after torn line: %v", err)
+	}
+	if got := loadTexts(t, store, "s"); len(got) != 2 || got[1] != "two" {
+		t.Fatalf("loaded %q, want the torn line replaced", got)
+	}
+}
+
+func TestFileStoreConcurrentAppends(t *testing.T) {
+	const (
+		writers = 8
+		appends = 20
+	)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := range writers {
+		// a store per writer, so only the file lock keeps lines apart
+		store := newFileStore(t, dir)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range appends {
+				if err := store.Append(ctx, "s", aiagent.NewUserMessage(fmt.Sprintf("%d-%d", w, i))); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("append: %v", err)
+	}
+
+	got := loadTexts(t, newFileStore(t, dir), "s")
+	if len(got) != writers*appends {
+		t.Fatalf("loaded %d messages, want %d", len(got), writers*appends)
+	}
+	seen := make(map[string]bool, len(got))
+	for _, text := range got {
+		seen[text] = true
+	}
+	if len(seen) != writers*appends {
+		t.Errorf("loaded %d distinct messages, want %d", len(seen), writers*appends)
+	}
+}
+
+func TestFileStoreReplace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newFileStore(t, dir)
+
+	err := store.Append(ctx, "s", aiagent.NewUserMessage("one"), aiagent.NewAssistantMessage("two"))
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err = store.Replace(ctx, "s", aiagent.NewSystemMessage("summary")); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if err = store.Append(ctx, "s", aiagent.NewUserMessage("three")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if got := loadTexts(t, store, "s"); len(got) != 2 || got[0] != "summary" || got[1] != "three" {
+		t.Errorf("loaded %q, want the replaced history", got)
+	}
+
+	ids, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "s" {
+		t.Errorf("sessions = %q, want only s and no temporary files", ids)
+	}
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newFileStore(t, t.TempDir())
+
+	if err := store.Append(ctx, "a/b", aiagent.NewUserMessage("one")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := store.Delete(ctx, "a/b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Load(ctx, "a/b"); !errors.Is(err, aiagent.ErrSessionNotFound) {
+		t.Errorf("load after delete: %v, want %v", err, aiagent.ErrSessionNotFound)
+	}
+	if err := store.Delete(ctx, "a/b"); !errors.Is(err, aiagent.ErrSessionNotFound) {
+		t.Errorf("second delete: %v, want %v", err, aiagent.ErrSessionNotFound)
+	}
+}
@@ -0,0 +1,73 @@
+package aiagent
+
+import "context"
+
+type StreamingLLM interface {
+	LLM
+	// Stream behaves like Call but reports partial output through onDelta
+	// while the response is being generated.
+	Stream(ctx context.Context, history []Message, onDelta func(Delta)) (Message, error)
+}
+
+type Delta struct {
+	Text     string         `json:"text,omitempty"`
+	ToolCall *ToolCallDelta `json:"tool_call,omitempty"`
+}
+
+type ToolCallDelta struct {
+	// Index is the position of the call within the tool request message.
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Args holds the arguments assembled so far, not only the latest chunk.
+	Args string `json:"args,omitempty"`
+}
+
+func (a *Agent) callLLM(ctx context.Context, history []Message, so sendOpts, iteration int) (Message, error) {
+	cfg := so.callConfig
+	cfg.Generation = a.generation.merge(cfg.Generation)
+	cfg.ToolChoice = so.toolChoice.at(iteration)
+	ctx = WithCallConfig(ctx, cfg)
+	if so.onDelta == nil {
+		return a.llm.Call(ctx, history)
+	}
+
+	return StreamCall(ctx, a.llm, history, so.onDelta)
+}
+
+// StreamCall streams from llm if it implements StreamingLLM. Otherwise it
+// calls llm and reports the whole response to onDelta at once. It lets LLM
+// wrappers support streaming regardless of the LLM they wrap.
+func StreamCall(ctx context.Context, llm LLM, history []Message, onDelta func(Delta)) (Message, error) {
+	if s, ok := llm.(StreamingLLM); ok {
+		return s.Stream(ctx, history, onDelta)
+	}
+
+	resp, err := llm.Call(ctx, history)
+	if err != nil {
+		return Message{}, err
+	}
+	emitWhole(resp, onDelta)
+
+	return resp, nil
+}
+
+// emitWhole reports a complete response as deltas for LLMs without streaming support.
+func emitWhole(resp Message, onDelta func(Delta)) {
+	switch resp.Type() { //nolint:exhaustive // only llm output types are emitted
+	case MessageTypeAssistant:
+		onDelta(Delta{Text: resp.MustText()})
+	case MessageTypeToolRequest:
+		if text, err := resp.Text(); err == nil {
+			onDelta(Delta{Text: text})
+		}
+		for i, req := range resp.MustToolCallRequests() {
+			onDelta(Delta{ToolCall: &ToolCallDelta{
+				Index: i,
+				ID:    req.Call.ID,
+				Name:  req.Call.Name,
+				Args:  string(req.Args),
+			}})
+		}
+	}
+}
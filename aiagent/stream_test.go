@@ -0,0 +1,42 @@
+package aiagent_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func TestStreamWithoutStreamingLLM(t *testing.T) {
+	step := aiagent.MustNewDerivedTool("step", "runs a step",
+		func(context.Context, stepArgs) (string, error) {
+			return "done", nil
+		})
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTool("step", stepArgs{Name: "a"}),
+		aiagenttest.Reply("finished"),
+	)
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(step))
+
+	var deltas []aiagent.Delta
+	res, err := agent.Stream(context.Background(), []aiagent.Message{aiagent.NewUserMessage("go")},
+		func(d aiagent.Delta) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if res.Text != "finished" {
+		t.Errorf("text = %q, want %q", res.Text, "finished")
+	}
+
+	if len(deltas) != 2 {
+		t.Fatalf("got %d deltas, want 2", len(deltas))
+	}
+	tc := deltas[0].ToolCall
+	if tc == nil || tc.Index != 0 || tc.Name != "step" || tc.Args != `{"name":"a"}` {
+		t.Errorf("first delta = %+v, want the whole tool call", tc)
+	}
+	if deltas[1].Text != "finished" {
+		t.Errorf("second delta = %+v, want the whole text", deltas[1])
+	}
+}
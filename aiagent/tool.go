@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	"unicode"
 )
 
 type NoArgs struct{}
@@ -53,16 +52,49 @@ func NewTool[T any](
 	return tool, nil
 }
 
+// MustNewDerivedTool is like NewDerivedTool but panics on error.
+func MustNewDerivedTool[T any](
+	name string,
+	desc string,
+	action func(context.Context, T) (string, error),
+) Tool {
+	tool, err := NewDerivedTool(name, desc, action)
+	if err != nil {
+		panic(err)
+	}
+
+	return tool
+}
+
+// NewDerivedTool is like NewTool with params derived from T, see DeriveParams.
+func NewDerivedTool[T any](
+	name string,
+	desc string,
+	action func(context.Context, T) (string, error),
+) (Tool, error) {
+	params, err := DeriveParams[T]()
+	if err != nil {
+		return nil, fmt.Errorf("derive params: %w", err)
+	}
+
+	return NewTool(name, desc, params, action)
+}
+
 type Param struct {
 	// Name must match the struct field’s "json" tag,
-	// or the default JSON key used by encoding/json.
-	Name        string
-	Type        ParamType
-	Description string
-	Required    bool
-	Enum        []any
-	Items       *Param
-	Properties  map[string]Param
+	// or the Go field name, which encoding/json uses for untagged fields.
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Required    bool      `json:"required,omitempty"`
+	Enum        []any     `json:"enum,omitempty"`
+	// Minimum and Maximum bound numbers, inclusively.
+	Minimum    *float64         `json:"minimum,omitempty"`
+	Maximum    *float64         `json:"maximum,omitempty"`
+	Items      *Param           `json:"items,omitempty"`
+	Properties map[string]Param `json:"properties,omitempty"`
+	// Values describes the values of an object with arbitrary keys, such as a map.
+	Values *Param `json:"values,omitempty"`
 }
 
 type ParamType uint
@@ -74,6 +106,8 @@ const (
 	ParamTypeBoolean
 	ParamTypeObject
 	ParamTypeArray
+	// ParamTypeAny accepts any JSON value, e.g. that of a json.RawMessage field.
+	ParamTypeAny
 )
 
 func validateTool[T any](tool *genericTool[T]) error {
@@ -120,19 +154,7 @@ func jsonFieldName(f reflect.StructField) (string, bool) {
 	if parts[0] != "" {
 		return parts[0], true
 	}
-	return defaultJSONName(f.Name), true
-}
-
-func defaultJSONName(fieldName string) string {
-	runes := []rune(fieldName)
-	if len(runes) == 0 {
-		return ""
-	}
-	if len(runes) > 1 && unicode.IsUpper(runes[0]) && unicode.IsUpper(runes[1]) {
-		return fieldName
-	}
-	runes[0] = unicode.ToLower(runes[0])
-	return string(runes)
+	return f.Name, true
 }
 
 type genericTool[T any] struct {
@@ -0,0 +1,90 @@
+package aiagent
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidToolChoice = errors.New("invalid tool choice")
+
+type ToolChoiceMode uint8
+
+const (
+	// ToolChoiceAuto lets the llm decide whether to call tools.
+	ToolChoiceAuto ToolChoiceMode = iota
+	// ToolChoiceNone forbids tool calls.
+	ToolChoiceNone
+	// ToolChoiceRequired makes the llm call at least one tool.
+	ToolChoiceRequired
+	// ToolChoiceTool makes the llm call ToolChoice.Tool.
+	ToolChoiceTool
+)
+
+func (m ToolChoiceMode) String() string {
+	switch m {
+	case ToolChoiceAuto:
+		return "auto"
+	case ToolChoiceNone:
+		return "none"
+	case ToolChoiceRequired:
+		return "required"
+	case ToolChoiceTool:
+		return "tool"
+	default:
+		return fmt.Sprintf("unknown_tool_choice_mode(%d)", m)
+	}
+}
+
+// ToolChoice controls tool use of the llm calls of a run. The zero value is
+// ToolChoiceAuto. A run forcing tool calls on every iteration only ends by
+// exceeding its budget, so forced choices are usually FirstIterationOnly.
+type ToolChoice struct {
+	Mode ToolChoiceMode `json:"mode,omitempty"`
+	// Tool is the name of the tool forced by ToolChoiceTool.
+	Tool string `json:"tool,omitempty"`
+	// FirstIterationOnly applies the choice to the first llm call of the run,
+	// the following calls use ToolChoiceAuto.
+	FirstIterationOnly bool `json:"first_iteration_only,omitempty"`
+}
+
+// ForceTool makes every llm call of the run call the named tool.
+func ForceTool(name string) ToolChoice {
+	return ToolChoice{Mode: ToolChoiceTool, Tool: name}
+}
+
+// ForceToolFirst makes the first llm call of the run call the named tool,
+// e.g. to always retrieve before answering.
+func ForceToolFirst(name string) ToolChoice {
+	return ToolChoice{Mode: ToolChoiceTool, Tool: name, FirstIterationOnly: true}
+}
+
+// at resolves the choice for the llm call made after iteration earlier ones.
+func (c ToolChoice) at(iteration int) ToolChoice {
+	if c.FirstIterationOnly && iteration > 0 {
+		return ToolChoice{}
+	}
+	c.FirstIterationOnly = false
+	return c
+}
+
+// WithToolChoice sets the tool choice of one run.
+func WithToolChoice(c ToolChoice) SendOption {
+	return func(o *sendOpts) {
+		o.toolChoice = c
+	}
+}
+
+func (a *Agent) validateToolChoice(c ToolChoice) error {
+	switch c.Mode {
+	case ToolChoiceAuto, ToolChoiceNone:
+	case ToolChoiceRequired:
+		if len(a.toolRegistry) == 0 {
+			return fmt.Errorf("%w: tool calls are required but no tools are registered", ErrInvalidToolChoice)
+		}
+	case ToolChoiceTool:
+		if _, ok := a.toolRegistry[c.Tool]; !ok {
+			return fmt.Errorf("%w: tool %q is not registered", ErrInvalidToolChoice, c.Tool)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package aiagent
+
+import (
+	"context"
+	"fmt"
+)
+
+type ToolErrorAction uint8
+
+const (
+	// ToolErrorReport sends the error text to the llm as the tool result,
+	// letting the model recover.
+	ToolErrorReport ToolErrorAction = iota
+	// ToolErrorAbort stops the run and returns the error from Send.
+	ToolErrorAbort
+)
+
+type ToolErrorPolicy struct {
+	// Retries is how many times a failed call is executed again
+	// before Action is applied.
+	Retries int
+	Action  ToolErrorAction
+}
+
+func WithToolErrorPolicy(p ToolErrorPolicy) AgentOption {
+	return func(a *Agent) {
+		a.toolErrorPolicy = p
+	}
+}
+
+type ToolError struct {
+	Request  ToolCallRequest
+	Attempts int
+	Err      error
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("call tool %s: %v", e.Request.Call.Name, e.Err)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+func (a *Agent) executeWithRetries(ctx context.Context, tool Tool, req ToolCallRequest) (string, *ToolError) {
+	var err error
+	for attempt := 1; attempt <= a.toolErrorPolicy.Retries+1; attempt++ {
+		var content string
+		content, err = tool.Execute(ctx, req.Args)
+		if err == nil {
+			return content, nil
+		}
+		if ctx.Err() != nil {
+			return "", &ToolError{Request: req, Attempts: attempt, Err: err}
+		}
+	}
+
+	return "", &ToolError{Request: req, Attempts: a.toolErrorPolicy.Retries + 1, Err: err}
+}
+
+func toolErrorResult(err *ToolError) string {
+	return "error: " + err.Err.Error()
+}
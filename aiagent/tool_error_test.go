@@ -0,0 +1,110 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+// flakyTool fails the first failures calls and succeeds afterwards.
+func flakyTool(failures int, calls *int) aiagent.Tool {
+	return aiagent.MustNewDerivedTool("flaky", "fails a few times",
+		func(context.Context, struct{}) (string, error) {
+			*calls++
+			if *calls <= failures {
+				return "", errors.New("unavailable")
+			}
+			return "ok", nil
+		})
+}
+
+func TestToolErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   aiagent.ToolErrorPolicy
+		failures int
+		calls    int
+		result   string
+		abort    bool
+		reported int
+		attempts int
+	}{
+		{
+			name:     "report",
+			policy:   aiagent.ToolErrorPolicy{Action: aiagent.ToolErrorReport},
+			failures: 1,
+			calls:    1,
+			result:   "error: unavailable",
+			reported: 1,
+			attempts: 1,
+		},
+		{
+			name:     "retry until success",
+			policy:   aiagent.ToolErrorPolicy{Retries: 2},
+			failures: 2,
+			calls:    3,
+			result:   "ok",
+		},
+		{
+			name:     "retries exhausted",
+			policy:   aiagent.ToolErrorPolicy{Retries: 2},
+			failures: 5,
+			calls:    3,
+			result:   "error: unavailable",
+			reported: 1,
+			attempts: 3,
+		},
+		{
+			name:     "abort",
+			policy:   aiagent.ToolErrorPolicy{Retries: 1, Action: aiagent.ToolErrorAbort},
+			failures: 5,
+			calls:    2,
+			abort:    true,
+			attempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			turns := []aiagenttest.Turn{aiagenttest.CallTool("flaky", struct{}{})}
+			if !tt.abort {
+				turns = append(turns, aiagenttest.Reply("done").
+					Expecting(aiagenttest.ToolResult("flaky", tt.result)))
+			}
+			llm := aiagenttest.NewFakeLLM(t, turns...)
+			agent := aiagent.NewAgent(llm,
+				aiagent.WithTool(flakyTool(tt.failures, &calls)),
+				aiagent.WithToolErrorPolicy(tt.policy),
+			)
+
+			res, err := agent.SendMessage(context.Background(), "go")
+			llm.AssertDone()
+			if calls != tt.calls {
+				t.Errorf("tool ran %d times, want %d", calls, tt.calls)
+			}
+
+			if tt.abort {
+				var toolErr *aiagent.ToolError
+				if !errors.As(err, &toolErr) {
+					t.Fatalf("err = %v, want *ToolError", err)
+				}
+				if toolErr.Attempts != tt.attempts {
+					t.Errorf("attempts = %d, want %d", toolErr.Attempts, tt.attempts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if len(res.ToolErrors) != tt.reported {
+				t.Fatalf("got %d reported tool errors, want %d", len(res.ToolErrors), tt.reported)
+			}
+			if tt.reported > 0 && res.ToolErrors[0].Attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", res.ToolErrors[0].Attempts, tt.attempts)
+			}
+		})
+	}
+}
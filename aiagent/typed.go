@@ -0,0 +1,200 @@
+package aiagent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const defaultTypedRetries = 2
+
+// Validator is implemented by structured output types with checks
+// beyond their JSON schema.
+type Validator interface {
+	Validate() error
+}
+
+// WithTypedRetries limits how many times SendTyped asks the llm again
+// after an answer that does not decode.
+func WithTypedRetries(n int) SendOption {
+	return func(o *sendOpts) {
+		o.typedRetries = n
+	}
+}
+
+// StructuredOutputError reports a final answer that still did not decode
+// after all retries.
+type StructuredOutputError struct {
+	Attempts int
+	// Text is the last answer.
+	Text string
+	Err  error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output invalid after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+// SendTyped runs chat like Agent.Send and decodes the final answer into T,
+// which must be a struct. The JSON schema derived from T is passed to the llm
+// as CallConfig.ResponseFormat and, for llms that do not support it, described
+// in a system message. An answer that does not decode or validate is sent back
+// to the llm with the error, up to WithTypedRetries times. Retries continue the
+// same run, so its budget, tool choice and interceptors span all attempts.
+// A run stopped for approval returns ErrPendingApproval; continue it with
+// Agent.Resume and decode the answer with DecodeTyped.
+func SendTyped[T any](ctx context.Context, a *Agent, chat []Message, opts ...SendOption) (T, RunResult, error) {
+	var zero T
+
+	typ := reflect.TypeFor[T]()
+	params, err := paramsOf(typ)
+	if err != nil {
+		return zero, RunResult{}, fmt.Errorf("structured output schema: %w", err)
+	}
+
+	so := newSendOpts(opts)
+	so.callConfig.ResponseFormat = &ResponseFormat{Name: indirect(typ).Name(), Params: params}
+
+	history, err := withSchemaPrompt(chat, params)
+	if err != nil {
+		return zero, RunResult{}, err
+	}
+
+	var (
+		v        T
+		attempts int
+	)
+	so.checkAnswer = func(resp Message) (Message, bool, error) {
+		attempts++
+		var errDecode error
+		if v, errDecode = DecodeTyped[T](resp.MustText()); errDecode == nil {
+			return Message{}, true, nil
+		}
+		if attempts > so.typedRetries {
+			errOutput := &StructuredOutputError{Attempts: attempts, Text: resp.MustText(), Err: errDecode}
+			return Message{}, false, errOutput
+		}
+		return NewUserMessage(fmt.Sprintf(
+			"Your answer is not valid: %v. Reply again with only the JSON object matching the schema.",
+			errDecode,
+		)), false, nil
+	}
+
+	result, err := a.run(ctx, newRunState(history), so, nil)
+	if err != nil {
+		return zero, result, err
+	}
+	if result.StopReason == StopReasonPendingApproval {
+		return zero, result, ErrPendingApproval
+	}
+	return v, result, nil
+}
+
+// DecodeTyped decodes a final answer into T, validating it against the schema
+// derived from T and, if T implements Validator, with Validate. A surrounding
+// markdown code fence is ignored.
+func DecodeTyped[T any](text string) (T, error) {
+	var v T
+
+	params, err := paramsOf(reflect.TypeFor[T]())
+	if err != nil {
+		return v, fmt.Errorf("structured output schema: %w", err)
+	}
+
+	raw := []byte(trimCodeFence(text))
+	if err = validateParams(raw, params); err != nil {
+		return v, err
+	}
+	if err = json.Unmarshal(raw, &v); err != nil {
+		return v, err
+	}
+	if val, ok := any(&v).(Validator); ok {
+		if err = val.Validate(); err != nil {
+			return v, err
+		}
+	}
+
+	return v, nil
+}
+
+// withSchemaPrompt adds a system message describing the expected answer
+// after the leading system messages of chat.
+func withSchemaPrompt(chat []Message, params []Param) ([]Message, error) {
+	schema, err := json.Marshal(describeParams(params))
+	if err != nil {
+		return nil, fmt.Errorf("structured output schema: %w", err)
+	}
+	prompt := NewSystemMessage("Give your final answer as a single JSON object without any other text. " +
+		"Properties marked required must be present. The object is described by: " + string(schema))
+
+	i := 0
+	for i < len(chat) && chat[i].Type() == MessageTypeSystem {
+		i++
+	}
+	history := make([]Message, 0, len(chat)+1)
+	history = append(history, chat[:i]...)
+	history = append(history, prompt)
+	return append(history, chat[i:]...), nil
+}
+
+// describeParams is a compact, model readable form of params.
+func describeParams(params []Param) map[string]any {
+	props := make(map[string]any, len(params))
+	for _, p := range params {
+		props[p.Name] = describeParam(p)
+	}
+	return props
+}
+
+func describeParam(p Param) map[string]any {
+	d := map[string]any{"type": paramTypeName(p.Type)}
+	if p.Required {
+		d["required"] = true
+	}
+	if p.Description != "" {
+		d["description"] = p.Description
+	}
+	if len(p.Enum) > 0 {
+		d["enum"] = p.Enum
+	}
+	if p.Minimum != nil {
+		d["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		d["maximum"] = *p.Maximum
+	}
+	if p.Items != nil {
+		d["items"] = describeParam(*p.Items)
+	}
+	if p.Values != nil {
+		d["values"] = describeParam(*p.Values)
+	}
+	if len(p.Properties) > 0 {
+		props := make(map[string]any, len(p.Properties))
+		for name, sub := range p.Properties {
+			props[name] = describeParam(sub)
+		}
+		d["properties"] = props
+	}
+	return d
+}
+
+func trimCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < len("``````") {
+		return text
+	}
+
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "```"), "```")
+	// drop the language of the fence, e.g. ```json
+	if i := strings.IndexByte(text, '\n'); i >= 0 && !strings.ContainsAny(text[:i], "{[") {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(text)
+}
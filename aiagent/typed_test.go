@@ -0,0 +1,167 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+type forecast struct {
+	City string `json:"city"`
+	Temp int    `json:"temp"`
+}
+
+// configLLM records the call config of every call it answers.
+type configLLM struct {
+	*aiagenttest.FakeLLM
+	configs *[]aiagent.CallConfig
+}
+
+func (l configLLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	*l.configs = append(*l.configs, aiagent.CallConfigFrom(ctx))
+	return l.FakeLLM.Call(ctx, history)
+}
+
+// finishes counts OnFinish calls and keeps the last error.
+func finishes(n *int, last *error) aiagent.AgentOption {
+	return aiagent.WithInterceptor(aiagent.Interceptor{
+		OnFinish: func(_ context.Context, _ aiagent.RunResult, err error) {
+			*n++
+			*last = err
+		},
+	})
+}
+
+func TestSendTypedRetriesInvalidAnswer(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.Reply(`{"city": 1}`),
+		aiagenttest.Reply("```json\n{\"city\": \"Paris\", \"temp\": 21}\n```"),
+	)
+	var (
+		finished  int
+		finishErr error
+	)
+	agent := aiagent.NewAgent(llm, finishes(&finished, &finishErr))
+
+	got, res, err := aiagent.SendTyped[forecast](context.Background(), agent,
+		[]aiagent.Message{aiagent.NewUserMessage("weather?")})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	if got != (forecast{City: "Paris", Temp: 21}) {
+		t.Errorf("forecast = %+v, want Paris 21", got)
+	}
+	if len(res.Steps) != 2 || res.StopReason != aiagent.StopReasonFinalAnswer {
+		t.Errorf("result has %d steps and %s, want 2 and %s", len(res.Steps), res.StopReason,
+			aiagent.StopReasonFinalAnswer)
+	}
+	retry := llm.Calls()[1]
+	if last := retry[len(retry)-1]; !strings.HasPrefix(last.MustText(), "Your answer is not valid") {
+		t.Errorf("retry message = %q, want the decode error", last.MustText())
+	}
+	if finished != 1 || finishErr != nil {
+		t.Errorf("OnFinish called %d times with %v, want once without error", finished, finishErr)
+	}
+}
+
+func TestSendTypedRetryKeepsToolChoice(t *testing.T) {
+	var configs []aiagent.CallConfig
+	llm := configLLM{
+		FakeLLM: aiagenttest.NewFakeLLM(t,
+			aiagenttest.CallTool("step", stepArgs{Name: "lookup"}),
+			aiagenttest.Reply("sunny"),
+			aiagenttest.Reply(`{"city": "Paris", "temp": 21}`),
+		),
+		configs: &configs,
+	}
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(stepTool()))
+
+	_, _, err := aiagent.SendTyped[forecast](context.Background(), agent,
+		[]aiagent.Message{aiagent.NewUserMessage("weather?")}, aiagent.WithToolChoice(aiagent.ForceToolFirst("step")))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+
+	modes := make([]aiagent.ToolChoiceMode, 0, len(configs))
+	for _, cfg := range configs {
+		modes = append(modes, cfg.ToolChoice.Mode)
+		if cfg.ResponseFormat == nil || cfg.ResponseFormat.Name != "forecast" {
+			t.Errorf("response format = %+v, want forecast", cfg.ResponseFormat)
+		}
+	}
+	want := []aiagent.ToolChoiceMode{aiagent.ToolChoiceTool, aiagent.ToolChoiceAuto, aiagent.ToolChoiceAuto}
+	if len(modes) != len(want) || modes[0] != want[0] || modes[1] != want[1] || modes[2] != want[2] {
+		t.Errorf("tool choices = %v, want the tool forced on the first call only", modes)
+	}
+}
+
+func TestSendTypedRetriesShareBudget(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("no json"), aiagenttest.Reply("still none"))
+	var (
+		finished  int
+		finishErr error
+	)
+	agent := aiagent.NewAgent(llm, aiagent.WithBudget(aiagent.Budget{MaxIterations: 2}),
+		finishes(&finished, &finishErr))
+
+	_, res, err := aiagent.SendTyped[forecast](context.Background(), agent,
+		[]aiagent.Message{aiagent.NewUserMessage("weather?")})
+	var budgetErr *aiagent.BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.Limit != aiagent.BudgetLimitIterations {
+		t.Fatalf("err = %v, want the iteration budget exceeded", err)
+	}
+	llm.AssertDone()
+
+	if len(res.Steps) != 2 {
+		t.Errorf("got %d steps, want both attempts", len(res.Steps))
+	}
+	if finished != 1 || !errors.Is(finishErr, err) {
+		t.Errorf("OnFinish called %d times with %v, want once with %v", finished, finishErr, err)
+	}
+}
+
+func TestSendTypedRetriesExhausted(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("no json"), aiagenttest.Reply("still none"))
+	var (
+		finished  int
+		finishErr error
+	)
+	agent := aiagent.NewAgent(llm, finishes(&finished, &finishErr))
+
+	_, res, err := aiagent.SendTyped[forecast](context.Background(), agent,
+		[]aiagent.Message{aiagent.NewUserMessage("weather?")}, aiagent.WithTypedRetries(1))
+	var outputErr *aiagent.StructuredOutputError
+	if !errors.As(err, &outputErr) {
+		t.Fatalf("err = %v, want *aiagent.StructuredOutputError", err)
+	}
+	llm.AssertDone()
+
+	if outputErr.Attempts != 2 || outputErr.Text != "still none" {
+		t.Errorf("error = %d attempts ending in %q, want 2 ending in the last answer",
+			outputErr.Attempts, outputErr.Text)
+	}
+	if res.StopReason != aiagent.StopReasonError || len(res.Steps) != 2 {
+		t.Errorf("result = %s with %d steps, want %s with 2", res.StopReason, len(res.Steps), aiagent.StopReasonError)
+	}
+	if finished != 1 || !errors.Is(finishErr, err) {
+		t.Errorf("OnFinish called %d times with %v, want once with %v", finished, finishErr, err)
+	}
+}
+
+func TestSendTypedPendingApproval(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("step", stepArgs{Name: "a"}))
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(aiagent.RequireApproval(stepTool())))
+
+	_, res, err := aiagent.SendTyped[forecast](context.Background(), agent,
+		[]aiagent.Message{aiagent.NewUserMessage("weather?")})
+	if !errors.Is(err, aiagent.ErrPendingApproval) || res.Pending == nil {
+		t.Errorf("err = %v with pending %v, want %v", err, res.Pending, aiagent.ErrPendingApproval)
+	}
+}
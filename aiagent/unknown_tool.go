@@ -0,0 +1,70 @@
+package aiagent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const defaultUnknownToolLimit = 3
+
+// UnknownToolsError is returned when the llm requested more calls to
+// unregistered tools during one run than the agent's limit allows.
+type UnknownToolsError struct {
+	Limit int
+	Calls []ToolCallRequest
+}
+
+func (e *UnknownToolsError) Error() string {
+	names := make([]string, 0, len(e.Calls))
+	for _, c := range e.Calls {
+		names = append(names, c.Call.Name)
+	}
+	return fmt.Sprintf("unknown tool limit %d exceeded: %s", e.Limit, strings.Join(names, ", "))
+}
+
+// WithUnknownToolLimit sets how many calls to unregistered tools are answered
+// with a "tool not found" response before the run fails with *UnknownToolsError.
+// n <= 0 removes the limit.
+func WithUnknownToolLimit(n int) AgentOption {
+	return func(a *Agent) {
+		a.unknownToolLimit = n
+	}
+}
+
+// WithUnknownToolHook registers f to be called for every requested tool
+// that is not registered in the agent.
+func WithUnknownToolHook(f func(ctx context.Context, req ToolCallRequest)) AgentOption {
+	return func(a *Agent) {
+		a.unknownToolHooks = append(a.unknownToolHooks, f)
+	}
+}
+
+func (a *Agent) unknownTools(ctx context.Context, reqs []ToolCallRequest) []ToolCallRequest {
+	var unknown []ToolCallRequest
+	for _, req := range reqs {
+		if _, ok := a.toolRegistry[req.Call.Name]; ok {
+			continue
+		}
+		unknown = append(unknown, req)
+		for _, hook := range a.unknownToolHooks {
+			hook(ctx, req)
+		}
+	}
+
+	return unknown
+}
+
+func (a *Agent) toolNotFoundResult(name string) string {
+	available := make([]string, 0, len(a.toolRegistry))
+	for n := range a.toolRegistry {
+		available = append(available, n)
+	}
+	slices.Sort(available)
+
+	if len(available) == 0 {
+		return fmt.Sprintf("error: tool %q not found, no tools are available", name)
+	}
+	return fmt.Sprintf("error: tool %q not found, available tools are: %s", name, strings.Join(available, ", "))
+}
@@ -0,0 +1,67 @@
+package aiagent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func TestUnknownToolIsAnswered(t *testing.T) {
+	step := aiagent.MustNewDerivedTool("step", "runs a step",
+		func(context.Context, stepArgs) (string, error) {
+			return "done", nil
+		})
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTool("missing", struct{}{}),
+		aiagenttest.Reply("sorry").Expecting(aiagenttest.ToolResult("missing",
+			`error: tool "missing" not found, available tools are: step`)),
+	)
+
+	var hooked []string
+	agent := aiagent.NewAgent(llm,
+		aiagent.WithTool(step),
+		aiagent.WithUnknownToolHook(func(_ context.Context, req aiagent.ToolCallRequest) {
+			hooked = append(hooked, req.Call.Name)
+		}),
+	)
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	llm.AssertDone()
+	if res.Text != "sorry" {
+		t.Errorf("text = %q, want %q", res.Text, "sorry")
+	}
+	if len(hooked) != 1 || hooked[0] != "missing" {
+		t.Errorf("hook saw %q, want [missing]", hooked)
+	}
+}
+
+func TestUnknownToolLimit(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTool("missing", struct{}{}),
+		aiagenttest.CallTools(
+			aiagenttest.ToolCall("missing", struct{}{}),
+			aiagenttest.ToolCall("other", struct{}{}),
+		),
+	)
+	agent := aiagent.NewAgent(llm, aiagent.WithUnknownToolLimit(2))
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	llm.AssertDone()
+
+	var unknownErr *aiagent.UnknownToolsError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("err = %v, want *UnknownToolsError", err)
+	}
+	if len(unknownErr.Calls) != 3 {
+		t.Errorf("got %d unknown calls, want 3", len(unknownErr.Calls))
+	}
+	if res.StopReason != aiagent.StopReasonError {
+		t.Errorf("stop reason = %s, want %s", res.StopReason, aiagent.StopReasonError)
+	}
+}
@@ -0,0 +1,134 @@
+package aiagent
+
+import (
+	"slices"
+	"strings"
+)
+
+const tokensPerMillion = 1_000_000
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// CachedTokens is the part of PromptTokens served from the prompt cache.
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	// ReasoningTokens is the part of CompletionTokens spent on reasoning.
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// UsageByModel sums usage per model name.
+type UsageByModel map[string]Usage
+
+func (u UsageByModel) add(m Message) {
+	if m.usage == (Usage{}) {
+		return
+	}
+	model, _ := m.Meta(MetaModel)
+	u[model] = u[model].Add(m.usage)
+}
+
+// Total sums the usage of all models.
+func (u UsageByModel) Total() Usage {
+	var total Usage
+	for _, usage := range u {
+		total = total.Add(usage)
+	}
+	return total
+}
+
+// Price is the cost per million tokens.
+type Price struct {
+	Input float64
+	// CachedInput applies to cached prompt tokens; Input is used if it is zero.
+	CachedInput float64
+	Output      float64
+}
+
+func (p Price) Cost(u Usage) float64 {
+	cached := p.CachedInput
+	if cached == 0 {
+		cached = p.Input
+	}
+
+	uncached := u.PromptTokens - u.CachedTokens
+	cost := float64(uncached)*p.Input + float64(u.CachedTokens)*cached + float64(u.CompletionTokens)*p.Output
+
+	return cost / tokensPerMillion
+}
+
+// Pricing maps model names to prices. Models are looked up with LookupModel,
+// so "gpt-4o" prices "gpt-4o-2024-08-06" but not "gpt-4o-mini". Providers
+// build it from their model lists, see the Pricing method of the openai registry.
+type Pricing map[string]Price
+
+func (p Pricing) Lookup(model string) (Price, bool) {
+	return LookupModel(p, model)
+}
+
+// Cost prices usage per model. Models missing from the table are returned
+// as unpriced and left out of the cost.
+func (p Pricing) Cost(usage UsageByModel) (float64, []string) {
+	var (
+		cost     float64
+		unpriced []string
+	)
+	for model, u := range usage {
+		price, ok := p.Lookup(model)
+		if !ok {
+			unpriced = append(unpriced, model)
+			continue
+		}
+		cost += price.Cost(u)
+	}
+	slices.Sort(unpriced)
+
+	return cost, unpriced
+}
+
+// LookupModel finds the entry of model in entries, which are keyed by model
+// name. Snapshots match their base model unless they have an entry themselves;
+// the longest matching base wins.
+func LookupModel[V any](entries map[string]V, model string) (V, bool) {
+	if v, ok := entries[model]; ok {
+		return v, true
+	}
+
+	var best string
+	for name := range entries {
+		if isSnapshotOf(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		var zero V
+		return zero, false
+	}
+	return entries[best], true
+}
+
+// isSnapshotOf reports whether name is base followed by a date or version
+// suffix, like "gpt-4o-2024-08-06" or "gpt-3.5-turbo-0125". Other suffixes
+// name a different model, e.g. "gpt-3.5-turbo-16k" or "gpt-4o-mini".
+func isSnapshotOf(name string, base string) bool {
+	rest, ok := strings.CutPrefix(name, base+"-")
+	if !ok {
+		return false
+	}
+	for part := range strings.SplitSeq(rest, "-") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package aiagent_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+)
+
+func pricing() aiagent.Pricing {
+	return aiagent.Pricing{
+		"gpt-4o":        {Input: 2.5, CachedInput: 1.25, Output: 10},
+		"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+		"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPricingLookup(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{model: "gpt-4o", want: "gpt-4o"},
+		{model: "gpt-4o-2024-08-06", want: "gpt-4o"},
+		// the longest base wins over a shorter one sharing its prefix
+		{model: "gpt-4o-mini", want: "gpt-4o-mini"},
+		{model: "gpt-4o-mini-2024-07-18", want: "gpt-4o-mini"},
+		{model: "gpt-4o-audio-preview", want: ""},
+		{model: "gpt-3.5-turbo-0125", want: "gpt-3.5-turbo"},
+		// a suffix that is not a date or version names another model
+		{model: "gpt-3.5-turbo-16k", want: ""},
+		{model: "gpt-4o-2024-08-06-", want: ""},
+		{model: "gpt-4", want: ""},
+		{model: "", want: ""},
+	}
+	p := pricing()
+	for _, tt := range tests {
+		price, ok := p.Lookup(tt.model)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Lookup(%q) = %+v, want no price", tt.model, price)
+			}
+			continue
+		}
+		if !ok || price != p[tt.want] {
+			t.Errorf("Lookup(%q) = %+v, %t, want the price of %s", tt.model, price, ok, tt.want)
+		}
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	u := aiagent.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000, CachedTokens: 400_000}
+
+	// 600k uncached input, 400k cached input and 500k output
+	if got, want := pricing()["gpt-4o"].Cost(u), 0.6*2.5+0.4*1.25+0.5*10; !closeTo(got, want) {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+	// cached tokens fall back to the input price
+	if got, want := pricing()["gpt-4o-mini"].Cost(u), 1*0.15+0.5*0.6; !closeTo(got, want) {
+		t.Errorf("cost without a cached price = %v, want %v", got, want)
+	}
+}
+
+func TestPricingCost(t *testing.T) {
+	usage := aiagent.UsageByModel{
+		"gpt-4o-2024-08-06": {PromptTokens: 1_000_000},
+		"gpt-4o-mini":       {CompletionTokens: 1_000_000},
+		"local-llama":       {PromptTokens: 10},
+		"":                  {PromptTokens: 10},
+	}
+
+	cost, unpriced := pricing().Cost(usage)
+	if want := 2.5 + 0.6; !closeTo(cost, want) {
+		t.Errorf("cost = %v, want %v", cost, want)
+	}
+	if want := []string{"", "local-llama"}; !slices.Equal(unpriced, want) {
+		t.Errorf("unpriced = %q, want %q", unpriced, want)
+	}
+}
+
+func TestRunUsageByModel(t *testing.T) {
+	reply := func(text, model string, tokens int) aiagenttest.Turn {
+		msg := aiagent.NewAssistantMessage(text)
+		msg = msg.WithUsage(aiagent.Usage{PromptTokens: tokens, TotalTokens: tokens})
+		return aiagenttest.Turn{Response: msg.WithMeta(aiagent.MetaModel, model)}
+	}
+	call := aiagenttest.CallTool("step", stepArgs{Name: "a"})
+	call.Response = call.Response.WithUsage(aiagent.Usage{PromptTokens: 5, TotalTokens: 5})
+	call.Response = call.Response.WithMeta(aiagent.MetaModel, "gpt-4o")
+
+	llm := aiagenttest.NewFakeLLM(t, call, reply("hi", "gpt-4o-mini", 7))
+	agent := aiagent.NewAgent(llm, aiagent.WithTool(stepTool()))
+
+	res, err := agent.SendMessage(context.Background(), "go")
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if got := res.UsageByModel["gpt-4o"].PromptTokens; got != 5 {
+		t.Errorf("gpt-4o prompt tokens = %d, want 5", got)
+	}
+	if got := res.UsageByModel["gpt-4o-mini"].PromptTokens; got != 7 {
+		t.Errorf("gpt-4o-mini prompt tokens = %d, want 7", got)
+	}
+	if total := res.UsageByModel.Total(); total != res.Usage || total.TotalTokens != 12 {
+		t.Errorf("total = %+v, usage = %+v, want both 12 tokens", total, res.Usage)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/wintermonth2298/agentus/aiagent"
 	"github.com/wintermonth2298/agentus/example/tools"
 	"github.com/wintermonth2298/agentus/llms/openai"
+	"github.com/wintermonth2298/agentus/llms/retry"
 )
 
 type authTransport struct {
@@ -25,10 +26,10 @@ func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 func main() {
 	proxyAPIKey := os.Getenv("PROXY_API_KEY")
 
-	client := newOpenAIClient(proxyAPIKey)
+	cfg := newOpenAIConfig(proxyAPIKey)
 
 	agent := aiagent.NewAgent(
-		openai.NewLLM(client, openai.ModelGPT4o),
+		retry.New(openai.MustNewLLM(cfg, openai.ModelGPT4o)),
 		aiagent.WithTool(tools.NewNumbersAdder()),
 		aiagent.WithTool(tools.NewRandomNumberGenerator()),
 		aiagent.WithTool(tools.NewTimeReporter()),
@@ -45,20 +46,23 @@ func main() {
 		panic(err)
 	}
 
-	fmt.Println(resp)
+	fmt.Println(resp.Text)
+
+	cost, _ := openai.DefaultRegistry().Pricing().Cost(resp.UsageByModel)
+	fmt.Printf("tokens: %d, cost: $%.6f\n", resp.Usage.TotalTokens, cost)
 }
 
-func newOpenAIClient(key string) *openaicli.Client {
+func newOpenAIConfig(key string) openaicli.ClientConfig {
 	// reqiured for proxyAPI
 	httpClient := &http.Client{
 		Transport: &authTransport{
-			wrapped: http.DefaultTransport,
+			wrapped: openai.NewRetryAfterTransport(http.DefaultTransport),
 			token:   key,
 		},
 	}
 
-	return openaicli.NewClientWithConfig(openaicli.ClientConfig{
+	return openaicli.ClientConfig{
 		BaseURL:    "https://api.proxyapi.ru/openai/v1",
 		HTTPClient: httpClient,
-	})
+	}
 }
@@ -10,18 +10,14 @@ import (
 )
 
 type RandomArgs struct {
-	Min int
-	Max int
+	Min int `json:"min" description:"Minimum value (inclusive)"`
+	Max int `json:"max" description:"Maximum value (inclusive)"`
 }
 
 func NewRandomNumberGenerator() aiagent.Tool {
-	return aiagent.MustNewTool(
+	return aiagent.MustNewDerivedTool(
 		"random_number",
 		"Generates a random integer number between min and max (inclusive).",
-		[]aiagent.Param{
-			{Name: "min", Type: aiagent.ParamTypeInteger, Description: "Minimum value (inclusive)", Required: true},
-			{Name: "max", Type: aiagent.ParamTypeInteger, Description: "Maximum value (inclusive)", Required: true},
-		},
 		func(_ context.Context, args RandomArgs) (string, error) {
 			if args.Min > args.Max {
 				return "", errors.New("min cannot be greater than max")
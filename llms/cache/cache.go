@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// MetaCache is the metadata key set to "hit" on messages served from the cache.
+const MetaCache = "cache"
+
+// LLM serves repeated identical calls from a Store instead of the wrapped LLM.
+// Calls are identical when their history, registered tools, model and
+// generation parameters are. The model is the one the wrapped LLM reports,
+// see aiagent.ModelName. Only successful responses are cached.
+type LLM struct {
+	llm        aiagent.LLM
+	store      Store
+	ttl        time.Duration
+	tools      []aiagent.Tool
+	onSetError []func(ctx context.Context, err error)
+}
+
+type Option func(*LLM)
+
+// New caches the responses of llm in store. The model name is part of
+// the cache key, so LLMs of different models can share a store.
+func New(llm aiagent.LLM, store Store, opts ...Option) *LLM {
+	l := &LLM{
+		llm:   llm,
+		store: store,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithTTL expires cached responses after d. Zero keeps them forever.
+func WithTTL(d time.Duration) Option {
+	return func(l *LLM) {
+		l.ttl = d
+	}
+}
+
+// WithOnSetError registers f to be called when a response could not be
+// written to the store. The call itself still succeeds.
+func WithOnSetError(f func(ctx context.Context, err error)) Option {
+	return func(l *LLM) {
+		l.onSetError = append(l.onSetError, f)
+	}
+}
+
+type bypassKey struct{}
+
+// Bypass returns a context whose calls skip the cache in both directions:
+// nothing is read from it and nothing is written to it.
+func Bypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassKey{}).(bool)
+	return b
+}
+
+func (l *LLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	if bypassed(ctx) {
+		return l.llm.Call(ctx, history)
+	}
+
+	key, err := l.key(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	msg, ok, err := l.store.Get(ctx, key)
+	if err != nil {
+		return aiagent.Message{}, fmt.Errorf("cache get: %w", err)
+	}
+	if ok {
+		return hit(msg), nil
+	}
+
+	resp, err := l.llm.Call(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	l.put(ctx, key, resp)
+
+	return resp, nil
+}
+
+func (l *LLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	if bypassed(ctx) {
+		return aiagent.StreamCall(ctx, l.llm, history, onDelta)
+	}
+
+	key, err := l.key(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	msg, ok, err := l.store.Get(ctx, key)
+	if err != nil {
+		return aiagent.Message{}, fmt.Errorf("cache get: %w", err)
+	}
+	if ok {
+		// a cached response is reported as a whole, like one of a non-streaming llm
+		return aiagent.StreamCall(ctx, cached(hit(msg)), history, onDelta)
+	}
+
+	resp, err := aiagent.StreamCall(ctx, l.llm, history, onDelta)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	l.put(ctx, key, resp)
+
+	return resp, nil
+}
+
+func (l *LLM) RegisterTool(tool aiagent.Tool) {
+	l.tools = append(l.tools, tool)
+	l.llm.RegisterTool(tool)
+}
+
+func (l *LLM) ModelName() string {
+	return aiagent.ModelName(l.llm)
+}
+
+func (l *LLM) put(ctx context.Context, key string, resp aiagent.Message) {
+	err := l.store.Set(ctx, key, resp, l.ttl)
+	if err == nil {
+		return
+	}
+	for _, f := range l.onSetError {
+		f(ctx, fmt.Errorf("cache set: %w", err))
+	}
+}
+
+// hit marks a cached message and drops its usage, since serving it cost nothing.
+func hit(msg aiagent.Message) aiagent.Message {
+	msg = msg.WithUsage(aiagent.Usage{})
+	return msg.WithMeta(MetaCache, "hit")
+}
+
+// key hashes a canonical form of everything that affects the response.
+// Usage and metadata of messages in the history are left out.
+func (l *LLM) key(ctx context.Context, history []aiagent.Message) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "model:%q\n", aiagent.ModelName(l.llm))
+
+	cfg := aiagent.CallConfigFrom(ctx)
+	writeGeneration(h, cfg.Generation)
+	fmt.Fprintf(h, "tool_choice:%s %q\n", cfg.ToolChoice.Mode, cfg.ToolChoice.Tool)
+	if f := cfg.ResponseFormat; f != nil {
+		fmt.Fprintf(h, "response_format:%q ", f.Name)
+		writeParams(h, f.Params)
+		fmt.Fprintln(h)
+	}
+
+	tools := slices.Clone(l.tools)
+	slices.SortFunc(tools, func(a, b aiagent.Tool) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	for _, t := range tools {
+		fmt.Fprintf(h, "tool:%q %q ", t.Name(), t.Desc())
+		writeParams(h, t.Params())
+		fmt.Fprintln(h)
+	}
+
+	enc := json.NewEncoder(h)
+	for _, m := range history {
+		km, err := newKeyMessage(m)
+		if err != nil {
+			return "", err
+		}
+		if err = enc.Encode(km); err != nil {
+			return "", fmt.Errorf("cache key: %w", err)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeParams prints params with nested properties in name order
+// and pointers followed, so equal schemas print the same.
+func writeParams(w io.Writer, params []aiagent.Param) {
+	for _, p := range params {
+		writeParam(w, p)
+	}
+}
+
+func writeParam(w io.Writer, p aiagent.Param) {
+	fmt.Fprintf(w, "{%q %d %q %t %#v", p.Name, p.Type, p.Description, p.Required, p.Enum)
+	if p.Minimum != nil {
+		fmt.Fprintf(w, " min:%v", *p.Minimum)
+	}
+	if p.Maximum != nil {
+		fmt.Fprintf(w, " max:%v", *p.Maximum)
+	}
+	if p.Items != nil {
+		fmt.Fprint(w, " items:")
+		writeParam(w, *p.Items)
+	}
+	if p.Values != nil {
+		fmt.Fprint(w, " values:")
+		writeParam(w, *p.Values)
+	}
+	for _, name := range slices.Sorted(maps.Keys(p.Properties)) {
+		fmt.Fprintf(w, " %q:", name)
+		writeParam(w, p.Properties[name])
+	}
+	fmt.Fprint(w, "}")
+}
+
+func writeGeneration(w io.Writer, g aiagent.GenerationConfig) {
+	fmt.Fprint(w, "generation:")
+	for _, v := range []*float64{g.Temperature, g.TopP, g.PresencePenalty, g.FrequencyPenalty} {
+		if v == nil {
+			fmt.Fprint(w, " -")
+		} else {
+			fmt.Fprintf(w, " %v", *v)
+		}
+	}
+	if g.Seed != nil {
+		fmt.Fprintf(w, " seed=%d", *g.Seed)
+	}
+	fmt.Fprintf(w, " max=%d stop=%q effort=%s\n", g.MaxTokens, g.Stop, g.ReasoningEffort)
+}
+
+type keyMessage struct {
+	Type         string                    `json:"type"`
+	Text         string                    `json:"text,omitempty"`
+	ToolCalls    []aiagent.ToolCallRequest `json:"tool_calls,omitempty"`
+	ToolResponse *aiagent.ToolCallResponse `json:"tool_response,omitempty"`
+}
+
+func newKeyMessage(m aiagent.Message) (keyMessage, error) {
+	km := keyMessage{Type: m.Type().String()}
+
+	switch m.Type() {
+	case aiagent.MessageTypeSystem, aiagent.MessageTypeUser, aiagent.MessageTypeAssistant:
+		km.Text = m.MustText()
+	case aiagent.MessageTypeToolRequest:
+		km.Text, _ = m.Text()
+		for _, req := range m.MustToolCallRequests() {
+			// equal arguments may differ in whitespace
+			var args bytes.Buffer
+			if err := json.Compact(&args, req.Args); err != nil {
+				return keyMessage{}, fmt.Errorf("cache key: tool call %s args: %w", req.Call.ID, err)
+			}
+			req.Args = args.Bytes()
+			km.ToolCalls = append(km.ToolCalls, req)
+		}
+	case aiagent.MessageTypeToolResponse:
+		resp := m.MustToolCallResponse()
+		km.ToolResponse = &resp
+	}
+
+	return km, nil
+}
+
+// cached is a non-streaming LLM answering with a fixed message.
+type cached aiagent.Message
+
+func (c cached) Call(context.Context, []aiagent.Message) (aiagent.Message, error) {
+	return aiagent.Message(c), nil
+}
+
+func (c cached) RegisterTool(aiagent.Tool) {}
@@ -0,0 +1,267 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+	"github.com/wintermonth2298/agentus/llms/cache"
+)
+
+// namedLLM is a fake llm calling the named model.
+type namedLLM struct {
+	*aiagenttest.FakeLLM
+	model string
+}
+
+func (l namedLLM) ModelName() string { return l.model }
+
+func history(text string) []aiagent.Message {
+	return []aiagent.Message{aiagent.NewUserMessage(text)}
+}
+
+func call(t *testing.T, ctx context.Context, l aiagent.LLM, text string) aiagent.Message {
+	t.Helper()
+
+	resp, err := l.Call(ctx, history(text))
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	return resp
+}
+
+func isHit(m aiagent.Message) bool {
+	v, _ := m.Meta(cache.MetaCache)
+	return v == "hit"
+}
+
+func TestCacheHit(t *testing.T) {
+	ctx := context.Background()
+	reply := aiagenttest.Reply("hello")
+	reply.Response = reply.Response.WithUsage(aiagent.Usage{TotalTokens: 10})
+	llm := aiagenttest.NewFakeLLM(t, reply)
+	c := cache.New(llm, cache.NewMemoryStore(0))
+
+	first := call(t, ctx, c, "hi")
+	second := call(t, ctx, c, "hi")
+	llm.AssertDone()
+
+	if isHit(first) || first.Usage().TotalTokens != 10 {
+		t.Errorf("first response = %v, want the llm's", first)
+	}
+	if !isHit(second) || second.MustText() != "hello" {
+		t.Errorf("second response = %v, want a cache hit", second)
+	}
+	if second.Usage() != (aiagent.Usage{}) {
+		t.Errorf("cache hit usage = %+v, want none", second.Usage())
+	}
+}
+
+func TestCacheMiss(t *testing.T) {
+	seed := 1
+	tests := []struct {
+		name   string
+		second func(ctx context.Context) (context.Context, string)
+	}{
+		{
+			name:   "history",
+			second: func(ctx context.Context) (context.Context, string) { return ctx, "bye" },
+		},
+		{
+			name: "generation",
+			second: func(ctx context.Context) (context.Context, string) {
+				cfg := aiagent.CallConfig{Generation: aiagent.GenerationConfig{Seed: &seed}}
+				return aiagent.WithCallConfig(ctx, cfg), "hi"
+			},
+		},
+		{
+			name: "tool choice",
+			second: func(ctx context.Context) (context.Context, string) {
+				cfg := aiagent.CallConfig{ToolChoice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceNone}}
+				return aiagent.WithCallConfig(ctx, cfg), "hi"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("one"), aiagenttest.Reply("two"))
+			c := cache.New(llm, cache.NewMemoryStore(0))
+
+			call(t, context.Background(), c, "hi")
+			ctx, text := tt.second(context.Background())
+			if resp := call(t, ctx, c, text); isHit(resp) {
+				t.Errorf("second call hit the cache")
+			}
+			llm.AssertDone()
+		})
+	}
+}
+
+func TestCacheKeyIncludesModel(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(0)
+	mini := namedLLM{aiagenttest.NewFakeLLM(t, aiagenttest.Reply("mini")), "gpt-4o-mini"}
+	full := namedLLM{aiagenttest.NewFakeLLM(t, aiagenttest.Reply("full")), "gpt-4o"}
+
+	call(t, ctx, cache.New(mini, store), "hi")
+	if resp := call(t, ctx, cache.New(full, store), "hi"); resp.MustText() != "full" {
+		t.Errorf("response = %q, want the other model's own answer", resp.MustText())
+	}
+	if resp := call(t, ctx, cache.New(mini, store), "hi"); !isHit(resp) || resp.MustText() != "mini" {
+		t.Errorf("response = %v, want the cached answer of the same model", resp)
+	}
+}
+
+func TestCacheKeyIncludesTools(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(0)
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("one"), aiagenttest.Reply("two"))
+
+	call(t, ctx, cache.New(llm, store), "hi")
+	withTool := cache.New(llm, store)
+	withTool.RegisterTool(aiagent.MustNewDerivedTool("step", "runs a step",
+		func(context.Context, struct{}) (string, error) { return "", nil }))
+	if resp := call(t, ctx, withTool, "hi"); isHit(resp) {
+		t.Error("call with a tool hit the entry cached without it")
+	}
+	llm.AssertDone()
+}
+
+func TestCacheBypass(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.Reply("one"), aiagenttest.Reply("two"), aiagenttest.Reply("three"))
+	c := cache.New(llm, cache.NewMemoryStore(0))
+
+	call(t, cache.Bypass(context.Background()), c, "hi")
+	if resp := call(t, context.Background(), c, "hi"); isHit(resp) {
+		t.Error("bypassed call was written to the cache")
+	}
+	if resp := call(t, cache.Bypass(context.Background()), c, "hi"); isHit(resp) {
+		t.Error("bypassed call was served from the cache")
+	}
+	llm.AssertDone()
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("one"), aiagenttest.Reply("two"))
+	c := cache.New(llm, cache.NewMemoryStore(0), cache.WithTTL(10*time.Millisecond))
+
+	call(t, ctx, c, "hi")
+	time.Sleep(20 * time.Millisecond)
+	if resp := call(t, ctx, c, "hi"); isHit(resp) {
+		t.Error("expired entry was served")
+	}
+	llm.AssertDone()
+}
+
+// failingStore fails every Set and optionally every Get.
+type failingStore struct {
+	cache.Store
+	getErr error
+}
+
+func (s failingStore) Get(ctx context.Context, key string) (aiagent.Message, bool, error) {
+	if s.getErr != nil {
+		return aiagent.Message{}, false, s.getErr
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (failingStore) Set(context.Context, string, aiagent.Message, time.Duration) error {
+	return errors.New("disk full")
+}
+
+func TestCacheIgnoresSetErrors(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("one"), aiagenttest.Reply("two"))
+	var setErrs []error
+	c := cache.New(llm, failingStore{Store: cache.NewMemoryStore(0)},
+		cache.WithOnSetError(func(_ context.Context, err error) { setErrs = append(setErrs, err) }))
+
+	for _, want := range []string{"one", "two"} {
+		if resp := call(t, context.Background(), c, "hi"); resp.MustText() != want {
+			t.Errorf("response = %q, want %q", resp.MustText(), want)
+		}
+	}
+	if len(setErrs) != 2 {
+		t.Errorf("got %d set errors, want 2", len(setErrs))
+	}
+}
+
+func TestCacheGetError(t *testing.T) {
+	errGet := errors.New("store down")
+	llm := aiagenttest.NewFakeLLM(t)
+	c := cache.New(llm, failingStore{Store: cache.NewMemoryStore(0), getErr: errGet})
+
+	if _, err := c.Call(context.Background(), history("hi")); !errors.Is(err, errGet) {
+		t.Errorf("err = %v, want %v", err, errGet)
+	}
+}
+
+func TestCacheStreamHit(t *testing.T) {
+	ctx := context.Background()
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello"))
+	c := cache.New(llm, cache.NewMemoryStore(0))
+	call(t, ctx, c, "hi")
+
+	var text string
+	resp, err := c.Stream(ctx, history("hi"), func(d aiagent.Delta) { text += d.Text })
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if !isHit(resp) || text != "hello" {
+		t.Errorf("stream = %v with deltas %q, want a hit reported as one delta", resp, text)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s := cache.NewMemoryStore(2)
+	for _, key := range []string{"a", "b"} {
+		if err := s.Set(ctx, key, aiagent.NewAssistantMessage(key), 0); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Fatal("a is missing")
+	}
+	if err := s.Set(ctx, "c", aiagent.NewAssistantMessage("c"), 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := s.Get(ctx, key); ok != want {
+			t.Errorf("%s cached = %t, want %t", key, ok, want)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := cache.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+
+	if _, ok, errGet := s.Get(ctx, "abc"); errGet != nil || ok {
+		t.Fatalf("get before set = %t, %v, want a miss", ok, errGet)
+	}
+	if err = s.Set(ctx, "abc", aiagent.NewAssistantMessage("hello"), 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	msg, ok, err := s.Get(ctx, "abc")
+	if err != nil || !ok || msg.MustText() != "hello" {
+		t.Errorf("get = %v, %t, %v, want the stored message", msg, ok, err)
+	}
+
+	if err = s.Set(ctx, "old", aiagent.NewAssistantMessage("old"), time.Nanosecond); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok, err = s.Get(ctx, "old"); err != nil || ok {
+		t.Errorf("get expired = %t, %v, want a miss", ok, err)
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// Store keeps cached responses by key. A ttl of zero means no expiry.
+type Store interface {
+	Get(ctx context.Context, key string) (aiagent.Message, bool, error)
+	Set(ctx context.Context, key string, msg aiagent.Message, ttl time.Duration) error
+}
+
+// MemoryStore is an in-memory Store evicting the least recently used entry
+// once it holds more than its capacity.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key       string
+	msg       aiagent.Message
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a store holding up to capacity entries.
+// Zero or negative capacity removes the limit.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (aiagent.Message, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return aiagent.Message{}, false, nil
+	}
+	e := el.Value.(*memoryEntry) //nolint:errcheck // the list only holds *memoryEntry
+	if expired(e.expiresAt) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return aiagent.Message{}, false, nil
+	}
+
+	s.order.MoveToFront(el)
+	return e.msg, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, msg aiagent.Message, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := &memoryEntry{key: key, msg: msg, expiresAt: expiry(ttl)}
+	if el, ok := s.entries[key]; ok {
+		el.Value = e
+		s.order.MoveToFront(el)
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(e)
+	if s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key) //nolint:errcheck // the list only holds *memoryEntry
+	}
+	return nil
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
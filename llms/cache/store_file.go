@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// FileStore keeps every cached response in its own JSON file inside
+// a directory. Files are replaced atomically, so concurrent writers
+// never leave a torn entry behind.
+type FileStore struct {
+	dir string
+}
+
+type fileEntry struct {
+	ExpiresAt time.Time       `json:"expires_at,omitzero"`
+	Message   aiagent.Message `json:"message"`
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (aiagent.Message, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return aiagent.Message{}, false, nil
+	}
+	if err != nil {
+		return aiagent.Message{}, false, fmt.Errorf("read cache file: %w", err)
+	}
+
+	var e fileEntry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return aiagent.Message{}, false, fmt.Errorf("decode cache file: %w", err)
+	}
+	if expired(e.ExpiresAt) {
+		if err = os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return aiagent.Message{}, false, fmt.Errorf("remove cache file: %w", err)
+		}
+		return aiagent.Message{}, false, nil
+	}
+
+	return e.Message, true, nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, msg aiagent.Message, ttl time.Duration) error {
+	data, err := json.Marshal(fileEntry{ExpiresAt: expiry(ttl), Message: msg})
+	if err != nil {
+		return fmt.Errorf("encode cache entry: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create cache file: %w", err)
+	}
+	// a no-op once the file is renamed
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close cache file: %w", err)
+	}
+	if err = os.Rename(f.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("rename cache file: %w", err)
+	}
+	return nil
+}
+
+// path is safe for any key produced by LLM, which is a hex digest.
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
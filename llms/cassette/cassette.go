@@ -0,0 +1,361 @@
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// maxLineSize bounds a single interaction in a cassette file.
+const maxLineSize = 64 << 20
+
+var ErrCassetteExhausted = errors.New("cassette exhausted")
+
+// Interaction is one recorded call: the history sent to the llm, the tools
+// registered on it, the call config passed in the context and the response.
+// Deltas holds the partial output of streamed calls in the order it arrived.
+// A cassette file holds one interaction per line.
+type Interaction struct {
+	Request  []aiagent.Message  `json:"request"`
+	Tools    []Tool             `json:"tools,omitempty"`
+	Config   aiagent.CallConfig `json:"config"`
+	Response aiagent.Message    `json:"response"`
+	Deltas   []aiagent.Delta    `json:"deltas,omitempty"`
+}
+
+// Tool is the schema of a registered tool.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Params      []aiagent.Param `json:"params,omitempty"`
+}
+
+// Recorder calls the wrapped LLM and appends every successful call
+// to a cassette file.
+type Recorder struct {
+	llm aiagent.LLM
+
+	mu    sync.Mutex
+	file  *os.File
+	tools []Tool
+}
+
+// NewRecorder starts a new cassette at path, replacing an existing one.
+func NewRecorder(llm aiagent.LLM, path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create cassette: %w", err)
+	}
+
+	return &Recorder{llm: llm, file: f}, nil
+}
+
+func (r *Recorder) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	resp, err := r.llm.Call(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+
+	return resp, r.record(ctx, history, resp, nil)
+}
+
+func (r *Recorder) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	var deltas []aiagent.Delta
+	resp, err := aiagent.StreamCall(ctx, r.llm, history, func(d aiagent.Delta) {
+		deltas = append(deltas, d)
+		onDelta(d)
+	})
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+
+	return resp, r.record(ctx, history, resp, deltas)
+}
+
+func (r *Recorder) RegisterTool(tool aiagent.Tool) {
+	r.mu.Lock()
+	r.tools = append(r.tools, newTool(tool))
+	r.mu.Unlock()
+
+	r.llm.RegisterTool(tool)
+}
+
+func (r *Recorder) ModelName() string {
+	return aiagent.ModelName(r.llm)
+}
+
+// Close closes the cassette file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+func (r *Recorder) record(
+	ctx context.Context,
+	history []aiagent.Message,
+	resp aiagent.Message,
+	deltas []aiagent.Delta,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.Marshal(Interaction{
+		Request:  history,
+		Tools:    r.tools,
+		Config:   aiagent.CallConfigFrom(ctx),
+		Response: resp,
+		Deltas:   deltas,
+	})
+	if err != nil {
+		return fmt.Errorf("encode interaction: %w", err)
+	}
+
+	if _, err = r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	if err = r.file.Sync(); err != nil {
+		return fmt.Errorf("sync cassette: %w", err)
+	}
+	return nil
+}
+
+// Replayer serves the responses of a cassette in the recorded order without
+// calling any llm. Every call must send the history, tools and call config
+// that were recorded for it, otherwise it fails with a *MismatchError.
+// Usage and metadata of the sent messages are not compared. Streamed calls
+// replay the recorded deltas, so a cassette recorded with Call or Stream
+// serves both.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int
+	tools        []Tool
+}
+
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette: %w", err)
+	}
+	defer f.Close()
+
+	var interactions []Interaction
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, maxLineSize)
+	for sc.Scan() {
+		var in Interaction
+		if err = json.Unmarshal(sc.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("decode cassette %s line %d: %w", path, len(interactions)+1, err)
+		}
+		interactions = append(interactions, in)
+	}
+	if err = sc.Err(); err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	return &Replayer{interactions: interactions}, nil
+}
+
+func (r *Replayer) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	in, err := r.replay(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+
+	return in.Response, nil
+}
+
+// Stream reports the deltas recorded for the call. An interaction recorded
+// without streaming is reported as a whole, like aiagent.StreamCall does for
+// llms that do not stream.
+func (r *Replayer) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	in, err := r.replay(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	if len(in.Deltas) == 0 {
+		return aiagent.StreamCall(ctx, recorded(in.Response), history, onDelta)
+	}
+
+	for _, d := range in.Deltas {
+		onDelta(d)
+	}
+	return in.Response, nil
+}
+
+func (r *Replayer) RegisterTool(tool aiagent.Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tools = append(r.tools, newTool(tool))
+}
+
+// Remaining reports how many recorded interactions were not replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.interactions) - r.next
+}
+
+// replay checks the call against the next interaction and consumes it.
+func (r *Replayer) replay(ctx context.Context, history []aiagent.Message) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.interactions) {
+		return Interaction{}, fmt.Errorf("%w: %d interactions replayed", ErrCassetteExhausted, r.next)
+	}
+	in := r.interactions[r.next]
+
+	want, err := requestLines(in)
+	if err != nil {
+		return Interaction{}, err
+	}
+	got, err := requestLines(Interaction{
+		Request: history,
+		Tools:   r.tools,
+		Config:  aiagent.CallConfigFrom(ctx),
+	})
+	if err != nil {
+		return Interaction{}, err
+	}
+	if !slices.Equal(want, got) {
+		return Interaction{}, &MismatchError{Interaction: r.next, Diff: diff(want, got)}
+	}
+
+	r.next++
+	return in, nil
+}
+
+// MismatchError reports a call whose request differs from the recorded one.
+// Diff prefixes recorded lines with "-" and sent lines with "+".
+type MismatchError struct {
+	Interaction int
+	Diff        string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("cassette interaction %d: request does not match the recording:\n%s", e.Interaction, e.Diff)
+}
+
+func newTool(t aiagent.Tool) Tool {
+	return Tool{Name: t.Name(), Description: t.Desc(), Params: t.Params()}
+}
+
+// requestLines renders the config, the tools sorted by name and the history
+// of in as indented JSON, leaving out the response. Messages are rendered
+// without version, usage and metadata.
+func requestLines(in Interaction) ([]string, error) {
+	lines, err := jsonLines(in.Config)
+	if err != nil {
+		return nil, fmt.Errorf("encode call config: %w", err)
+	}
+
+	tools := slices.Clone(in.Tools)
+	slices.SortStableFunc(tools, func(a, b Tool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, t := range tools {
+		tl, errTool := jsonLines(t)
+		if errTool != nil {
+			return nil, fmt.Errorf("encode tool %s: %w", t.Name, errTool)
+		}
+		lines = append(lines, tl...)
+	}
+
+	for _, m := range in.Request {
+		data, errMsg := json.Marshal(m)
+		if errMsg != nil {
+			return nil, fmt.Errorf("encode message: %w", errMsg)
+		}
+
+		var fields map[string]json.RawMessage
+		if err = json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("decode message: %w", err)
+		}
+		delete(fields, "usage")
+		delete(fields, "meta")
+		delete(fields, "version")
+
+		ml, errMsg := jsonLines(fields)
+		if errMsg != nil {
+			return nil, fmt.Errorf("encode message: %w", errMsg)
+		}
+		lines = append(lines, ml...)
+	}
+	return lines, nil
+}
+
+func jsonLines(v any) ([]string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// diff is a line diff of want and got based on their longest common subsequence.
+func diff(want, got []string) string {
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			b.WriteString("  " + want[i] + "\n")
+			i++
+			j++
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			b.WriteString("- " + want[i] + "\n")
+			i++
+		default:
+			b.WriteString("+ " + got[j] + "\n")
+			j++
+		}
+	}
+	return b.String()
+}
+
+// recorded is a non-streaming llm answering with a recorded response.
+type recorded aiagent.Message
+
+func (m recorded) Call(context.Context, []aiagent.Message) (aiagent.Message, error) {
+	return aiagent.Message(m), nil
+}
+
+func (m recorded) RegisterTool(aiagent.Tool) {}
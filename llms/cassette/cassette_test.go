@@ -0,0 +1,240 @@
+package cassette_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+	"github.com/wintermonth2298/agentus/llms/cassette"
+)
+
+type stepArgs struct {
+	Name string `json:"name"`
+}
+
+func stepTool() aiagent.Tool {
+	return aiagent.MustNewDerivedTool("step", "runs a step",
+		func(_ context.Context, args stepArgs) (string, error) { return args.Name + " done", nil })
+}
+
+func history(text string) []aiagent.Message {
+	return []aiagent.Message{aiagent.NewUserMessage(text)}
+}
+
+// record runs f against a recorder of llm and returns the cassette path.
+func record(t *testing.T, llm aiagent.LLM, f func(r *cassette.Recorder)) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	r, err := cassette.NewRecorder(llm, path)
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	f(r)
+	if err = r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return path
+}
+
+func replayer(t *testing.T, path string) *cassette.Replayer {
+	t.Helper()
+
+	r, err := cassette.NewReplayer(path)
+	if err != nil {
+		t.Fatalf("new replayer: %v", err)
+	}
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.CallTool("step", stepArgs{Name: "first"}),
+		aiagenttest.Reply("all done"),
+	)
+	var recorded aiagent.RunResult
+	path := record(t, llm, func(r *cassette.Recorder) {
+		var err error
+		recorded, err = aiagent.NewAgent(r, aiagent.WithTool(stepTool())).SendMessage(context.Background(), "go")
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	})
+	llm.AssertDone()
+
+	r := replayer(t, path)
+	replayed, err := aiagent.NewAgent(r, aiagent.WithTool(stepTool())).SendMessage(context.Background(), "go")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed.Message.MustText() != "all done" || recorded.Message.MustText() != "all done" {
+		t.Errorf("replayed %q, recorded %q, want all done", replayed.Message.MustText(), recorded.Message.MustText())
+	}
+	aiagenttest.AssertToolSequence(t, replayed, "step")
+	if r.Remaining() != 0 {
+		t.Errorf("remaining = %d, want 0", r.Remaining())
+	}
+
+	if _, err = r.Call(context.Background(), history("go")); !errors.Is(err, cassette.ErrCassetteExhausted) {
+		t.Errorf("err = %v, want %v", err, cassette.ErrCassetteExhausted)
+	}
+}
+
+func TestReplayIgnoresUsageAndMeta(t *testing.T) {
+	path := record(t, aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello")), func(r *cassette.Recorder) {
+		if _, err := r.Call(context.Background(), history("hi")); err != nil {
+			t.Fatalf("call: %v", err)
+		}
+	})
+
+	sent := aiagent.NewUserMessage("hi")
+	sent = sent.WithUsage(aiagent.Usage{TotalTokens: 3})
+	sent = sent.WithMeta("trace", "abc")
+	resp, err := replayer(t, path).Call(context.Background(), []aiagent.Message{sent})
+	if err != nil || resp.MustText() != "hello" {
+		t.Errorf("replay = %v, %v, want hello", resp, err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := record(t, aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello")), func(r *cassette.Recorder) {
+		r.RegisterTool(stepTool())
+		if _, err := r.Call(context.Background(), history("hi")); err != nil {
+			t.Fatalf("call: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		call func(r *cassette.Replayer) error
+		want []string
+	}{
+		{
+			name: "history",
+			call: func(r *cassette.Replayer) error {
+				r.RegisterTool(stepTool())
+				_, err := r.Call(context.Background(), history("bye"))
+				return err
+			},
+			want: []string{`-   "text": "hi",`, `+   "text": "bye",`},
+		},
+		{
+			name: "tools",
+			call: func(r *cassette.Replayer) error {
+				_, err := r.Call(context.Background(), history("hi"))
+				return err
+			},
+			want: []string{`-   "name": "step",`},
+		},
+		{
+			name: "config",
+			call: func(r *cassette.Replayer) error {
+				r.RegisterTool(stepTool())
+				ctx := aiagent.WithCallConfig(context.Background(),
+					aiagent.CallConfig{ToolChoice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceRequired}})
+				_, err := r.Call(ctx, history("hi"))
+				return err
+			},
+			want: []string{`-   "tool_choice": {}`, `+   "tool_choice": {`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := replayer(t, path)
+
+			var mismatch *cassette.MismatchError
+			if err := tt.call(r); !errors.As(err, &mismatch) {
+				t.Fatalf("err = %v, want *cassette.MismatchError", err)
+			}
+			if mismatch.Interaction != 0 {
+				t.Errorf("interaction = %d, want 0", mismatch.Interaction)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(mismatch.Diff, line+"\n") {
+					t.Errorf("diff has no line %q:\n%s", line, mismatch.Diff)
+				}
+			}
+			if r.Remaining() != 1 {
+				t.Errorf("remaining = %d, want the mismatched interaction kept", r.Remaining())
+			}
+		})
+	}
+}
+
+func TestReplayStreamsRecordedDeltas(t *testing.T) {
+	deltas := []aiagent.Delta{
+		{Text: "hel"},
+		{Text: "lo"},
+		{ToolCall: &aiagent.ToolCallDelta{Index: 0, ID: "call_1", Name: "step", Args: `{"name":`}},
+		{ToolCall: &aiagent.ToolCallDelta{Index: 0, Args: `{"name":"first"}`}},
+	}
+	llm := streamingLLM{
+		FakeLLM: aiagenttest.NewFakeLLM(t, aiagenttest.CallTool("step", stepArgs{Name: "first"})),
+		deltas:  deltas,
+	}
+
+	var got []aiagent.Delta
+	path := record(t, llm, func(r *cassette.Recorder) {
+		_, err := r.Stream(context.Background(), history("hi"), func(d aiagent.Delta) { got = append(got, d) })
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	})
+	if !reflect.DeepEqual(got, deltas) {
+		t.Errorf("recorder passed on deltas %+v, want %+v", got, deltas)
+	}
+
+	got = nil
+	resp, err := replayer(t, path).Stream(context.Background(), history("hi"), func(d aiagent.Delta) {
+		got = append(got, d)
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if !reflect.DeepEqual(got, deltas) {
+		t.Errorf("replayed deltas %+v, want %+v", got, deltas)
+	}
+	if reqs := resp.MustToolCallRequests(); len(reqs) != 1 || reqs[0].Call.Name != "step" {
+		t.Errorf("replayed response %v, want the recorded tool request", resp)
+	}
+}
+
+func TestReplayStreamsCallAsWhole(t *testing.T) {
+	path := record(t, aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello")), func(r *cassette.Recorder) {
+		if _, err := r.Call(context.Background(), history("hi")); err != nil {
+			t.Fatalf("call: %v", err)
+		}
+	})
+
+	var got []aiagent.Delta
+	if _, err := replayer(t, path).Stream(context.Background(), history("hi"), func(d aiagent.Delta) {
+		got = append(got, d)
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if want := []aiagent.Delta{{Text: "hello"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deltas = %+v, want %+v", got, want)
+	}
+}
+
+// streamingLLM answers like the fake llm and streams fixed deltas first.
+type streamingLLM struct {
+	*aiagenttest.FakeLLM
+	deltas []aiagent.Delta
+}
+
+func (l streamingLLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	for _, d := range l.deltas {
+		onDelta(d)
+	}
+	return l.Call(ctx, history)
+}
@@ -0,0 +1,153 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// MetaBackend is the metadata key holding the name of the backend
+// that produced a message.
+const MetaBackend = "backend"
+
+var ErrNoBackends = errors.New("no backends")
+
+type Backend struct {
+	Name string
+	LLM  aiagent.LLM
+}
+
+// LLM calls its backends in order and falls through to the next one when
+// a call fails with an error that qualifies for fallback.
+type LLM struct {
+	backends   []Backend
+	fallbackOn func(err error) bool
+	onFallback []func(ctx context.Context, from Backend, err error)
+}
+
+type Option func(*LLM)
+
+// New returns an LLM over backends, tried in the given order. By default it
+// falls back on rate limit, quota, server and network failures.
+func New(backends []Backend, opts ...Option) (*LLM, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackends
+	}
+
+	l := &LLM{
+		backends: slices.Clone(backends),
+		fallbackOn: classIn(
+			aiagent.LLMErrorRateLimit,
+			aiagent.LLMErrorQuota,
+			aiagent.LLMErrorServer,
+			aiagent.LLMErrorNetwork,
+		),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l, nil
+}
+
+// WithFallbackOn replaces the error classes that trigger a fallback.
+func WithFallbackOn(classes ...aiagent.LLMErrorClass) Option {
+	return func(l *LLM) {
+		l.fallbackOn = classIn(classes...)
+	}
+}
+
+// WithFallbackIf replaces the decision which errors trigger a fallback.
+func WithFallbackIf(f func(err error) bool) Option {
+	return func(l *LLM) {
+		l.fallbackOn = f
+	}
+}
+
+// WithOnFallback registers f to be called whenever a backend failed
+// and the next one is tried.
+func WithOnFallback(f func(ctx context.Context, from Backend, err error)) Option {
+	return func(l *LLM) {
+		l.onFallback = append(l.onFallback, f)
+	}
+}
+
+func (l *LLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	return l.do(ctx, func(ctx context.Context, b Backend) (aiagent.Message, bool, error) {
+		resp, err := b.LLM.Call(ctx, history)
+		return resp, true, err
+	})
+}
+
+// Stream falls back only on failures before the first delta,
+// so callers never see parts of two responses.
+func (l *LLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	return l.do(ctx, func(ctx context.Context, b Backend) (aiagent.Message, bool, error) {
+		var emitted bool
+		resp, err := aiagent.StreamCall(ctx, b.LLM, history, func(d aiagent.Delta) {
+			emitted = true
+			onDelta(d)
+		})
+		return resp, !emitted, err
+	})
+}
+
+// RegisterTool registers tool on every backend, so any of them can answer
+// with the same tools.
+func (l *LLM) RegisterTool(tool aiagent.Tool) {
+	for _, b := range l.backends {
+		b.LLM.RegisterTool(tool)
+	}
+}
+
+// ModelName lists the models of the backends in order, separated by commas,
+// since any of them may answer a call.
+func (l *LLM) ModelName() string {
+	names := make([]string, 0, len(l.backends))
+	for _, b := range l.backends {
+		names = append(names, aiagent.ModelName(b.LLM))
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *LLM) do(
+	ctx context.Context,
+	call func(ctx context.Context, b Backend) (aiagent.Message, bool, error),
+) (aiagent.Message, error) {
+	errs := make([]error, 0, len(l.backends))
+	for i, b := range l.backends {
+		resp, canFallback, err := call(ctx, b)
+		if err == nil {
+			return resp.WithMeta(MetaBackend, b.Name), nil
+		}
+
+		err = fmt.Errorf("backend %s: %w", b.Name, err)
+		errs = append(errs, err)
+		last := i == len(l.backends)-1
+		if last || !canFallback || ctx.Err() != nil || !l.fallbackOn(err) {
+			break
+		}
+		for _, f := range l.onFallback {
+			f(ctx, b, err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return aiagent.Message{}, errs[0]
+	}
+	return aiagent.Message{}, errors.Join(errs...)
+}
+
+func classIn(classes ...aiagent.LLMErrorClass) func(err error) bool {
+	return func(err error) bool {
+		return slices.Contains(classes, aiagent.ClassifyLLMError(err))
+	}
+}
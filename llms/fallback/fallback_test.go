@@ -0,0 +1,170 @@
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+	"github.com/wintermonth2298/agentus/llms/fallback"
+)
+
+func llmError(class aiagent.LLMErrorClass) error {
+	return &aiagent.LLMError{Class: class, Err: errors.New(class.String())}
+}
+
+func history() []aiagent.Message {
+	return []aiagent.Message{aiagent.NewUserMessage("hi")}
+}
+
+func TestFallbackOrder(t *testing.T) {
+	primary := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(llmError(aiagent.LLMErrorRateLimit)))
+	secondary := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(llmError(aiagent.LLMErrorServer)))
+	tertiary := aiagenttest.NewFakeLLM(t, aiagenttest.Reply("hello"))
+
+	var fellBack []string
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: primary},
+		{Name: "secondary", LLM: secondary},
+		{Name: "tertiary", LLM: tertiary},
+	}, fallback.WithOnFallback(func(_ context.Context, from fallback.Backend, _ error) {
+		fellBack = append(fellBack, from.Name)
+	}))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	resp, err := l.Call(context.Background(), history())
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if backend, _ := resp.Meta(fallback.MetaBackend); backend != "tertiary" || resp.MustText() != "hello" {
+		t.Errorf("response %q from %q, want hello from tertiary", resp.MustText(), backend)
+	}
+	if len(fellBack) != 2 || fellBack[0] != "primary" || fellBack[1] != "secondary" {
+		t.Errorf("fell back from %q, want primary and secondary", fellBack)
+	}
+	primary.AssertDone()
+	secondary.AssertDone()
+	tertiary.AssertDone()
+}
+
+func TestFallbackPassesThroughPermanentErrors(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorInvalidRequest)
+	primary := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall))
+	// the secondary has no turns, so calling it fails the test
+	secondary := aiagenttest.NewFakeLLM(t)
+
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: primary},
+		{Name: "secondary", LLM: secondary},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	_, err = l.Call(context.Background(), history())
+	if !errors.Is(err, errCall) {
+		t.Fatalf("err = %v, want %v", err, errCall)
+	}
+	if got := aiagent.ClassifyLLMError(err); got != aiagent.LLMErrorInvalidRequest {
+		t.Errorf("class = %s, want %s", got, aiagent.LLMErrorInvalidRequest)
+	}
+}
+
+func TestFallbackAllFail(t *testing.T) {
+	errPrimary := llmError(aiagent.LLMErrorRateLimit)
+	errSecondary := llmError(aiagent.LLMErrorQuota)
+
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errPrimary))},
+		{Name: "secondary", LLM: aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errSecondary))},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	_, err = l.Call(context.Background(), history())
+	if !errors.Is(err, errPrimary) || !errors.Is(err, errSecondary) {
+		t.Errorf("err = %v, want both backend errors", err)
+	}
+}
+
+func TestFallbackOn(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorServer)
+	secondary := aiagenttest.NewFakeLLM(t)
+
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall))},
+		{Name: "secondary", LLM: secondary},
+	}, fallback.WithFallbackOn(aiagent.LLMErrorRateLimit))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	if _, err = l.Call(context.Background(), history()); !errors.Is(err, errCall) {
+		t.Errorf("err = %v, want %v", err, errCall)
+	}
+}
+
+func TestFallbackRegistersToolsEverywhere(t *testing.T) {
+	primary, secondary := aiagenttest.NewFakeLLM(t), aiagenttest.NewFakeLLM(t)
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: primary},
+		{Name: "secondary", LLM: secondary},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	l.RegisterTool(aiagent.MustNewDerivedTool("step", "runs a step",
+		func(context.Context, struct{}) (string, error) { return "", nil }))
+	primary.AssertTools("step")
+	secondary.AssertTools("step")
+}
+
+func TestFallbackNoBackends(t *testing.T) {
+	if _, err := fallback.New(nil); !errors.Is(err, fallback.ErrNoBackends) {
+		t.Errorf("err = %v, want %v", err, fallback.ErrNoBackends)
+	}
+}
+
+func TestFallbackStreamAfterDelta(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorServer)
+	partial := streamFunc(func(onDelta func(aiagent.Delta)) (aiagent.Message, error) {
+		onDelta(aiagent.Delta{Text: "hel"})
+		return aiagent.Message{}, errCall
+	})
+	secondary := aiagenttest.NewFakeLLM(t)
+
+	l, err := fallback.New([]fallback.Backend{
+		{Name: "primary", LLM: partial},
+		{Name: "secondary", LLM: secondary},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	var deltas []aiagent.Delta
+	_, err = l.Stream(context.Background(), history(), func(d aiagent.Delta) { deltas = append(deltas, d) })
+	if !errors.Is(err, errCall) || len(deltas) != 1 {
+		t.Errorf("err = %v after %d deltas, want %v after one", err, len(deltas), errCall)
+	}
+}
+
+type streamFunc func(onDelta func(aiagent.Delta)) (aiagent.Message, error)
+
+func (f streamFunc) Call(context.Context, []aiagent.Message) (aiagent.Message, error) {
+	return f(func(aiagent.Delta) {})
+}
+
+func (f streamFunc) Stream(
+	_ context.Context,
+	_ []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	return f(onDelta)
+}
+
+func (streamFunc) RegisterTool(aiagent.Tool) {}
@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+const (
+	codeInsufficientQuota     = "insufficient_quota"
+	codeContextLengthExceeded = "context_length_exceeded"
+)
+
+// retryInMessage matches the hint in OpenAI rate limit messages,
+// e.g. "Please try again in 1.5s" or "in 20ms".
+var retryInMessage = regexp.MustCompile(`try again in (\d+(?:\.\d+)?)(ms|s)`)
+
+// classifyError wraps err into *aiagent.LLMError. retryAfter is the delay
+// captured from the response headers, see NewRetryAfterTransport.
+func classifyError(err error, retryAfter time.Duration) error {
+	llmErr := &aiagent.LLMError{Class: aiagent.LLMErrorUnknown, RetryAfter: retryAfter, Err: err}
+
+	var (
+		apiErr *openai.APIError
+		reqErr *openai.RequestError
+		netErr net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.As(err, &apiErr):
+		llmErr.StatusCode = apiErr.HTTPStatusCode
+		llmErr.Class = classifyStatus(apiErr.HTTPStatusCode, apiErr.Code)
+		if llmErr.RetryAfter == 0 {
+			llmErr.RetryAfter = parseRetryIn(apiErr.Message)
+		}
+	case errors.As(err, &reqErr):
+		llmErr.StatusCode = reqErr.HTTPStatusCode
+		llmErr.Class = classifyStatus(reqErr.HTTPStatusCode, nil)
+	case errors.As(err, &netErr):
+		llmErr.Class = aiagent.LLMErrorNetwork
+	}
+
+	return llmErr
+}
+
+func classifyStatus(status int, code any) aiagent.LLMErrorClass {
+	switch {
+	case status == http.StatusTooManyRequests && code == codeInsufficientQuota:
+		return aiagent.LLMErrorQuota
+	case status == http.StatusTooManyRequests:
+		return aiagent.LLMErrorRateLimit
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return aiagent.LLMErrorAuth
+	case code == codeContextLengthExceeded:
+		return aiagent.LLMErrorContextLength
+	case status == http.StatusRequestTimeout, status == http.StatusConflict, status >= http.StatusInternalServerError:
+		return aiagent.LLMErrorServer
+	case status >= http.StatusBadRequest:
+		return aiagent.LLMErrorInvalidRequest
+	default:
+		return aiagent.LLMErrorUnknown
+	}
+}
+
+func parseRetryIn(msg string) time.Duration {
+	m := retryInMessage.FindStringSubmatch(msg)
+	if m == nil {
+		return 0
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	if m[2] == "ms" {
+		return time.Duration(v * float64(time.Millisecond))
+	}
+	return time.Duration(v * float64(time.Second))
+}
+
+// NewRetryAfterTransport wraps rt to capture the Retry-After headers of
+// throttled and failed responses, which go-openai does not expose. Use it in
+// the http.Client of the openai client to have LLM errors carry RetryAfter.
+func NewRetryAfterTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &retryAfterTransport{wrapped: rt}
+}
+
+type retryAfterTransport struct {
+	wrapped http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.wrapped.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+
+	if slot, ok := req.Context().Value(retryAfterKey{}).(*retryAfterSlot); ok {
+		slot.set(parseRetryAfter(resp.Header))
+	}
+	return resp, nil
+}
+
+type retryAfterKey struct{}
+
+type retryAfterSlot struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func withRetryAfterSlot(ctx context.Context) (context.Context, *retryAfterSlot) {
+	slot := &retryAfterSlot{}
+	return context.WithValue(ctx, retryAfterKey{}, slot), slot
+}
+
+func (s *retryAfterSlot) set(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+func (s *retryAfterSlot) get() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay
+}
+
+// parseRetryAfter reads OpenAI's retry-after-ms header or the standard
+// Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	v := h.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
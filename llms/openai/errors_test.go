@@ -0,0 +1,134 @@
+package openai_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	goopenai "github.com/sashabaranov/go-openai"
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/llms/openai"
+)
+
+// failWith answers with an api error of the given status, code and message.
+func failWith(status int, code string, message string, header http.Header) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":{"message":%q,"type":"error","code":%q}}`, message, code)
+	}
+}
+
+func callError(t *testing.T, llm *openai.LLM) *aiagent.LLMError {
+	t.Helper()
+
+	_, err := llm.Call(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")})
+	var llmErr *aiagent.LLMError
+	if !errors.As(err, &llmErr) {
+		t.Fatalf("err = %v, want *aiagent.LLMError", err)
+	}
+	return llmErr
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   string
+		class  aiagent.LLMErrorClass
+	}{
+		{name: "rate limit", status: http.StatusTooManyRequests, code: "rate_limit_exceeded",
+			class: aiagent.LLMErrorRateLimit},
+		{name: "quota", status: http.StatusTooManyRequests, code: "insufficient_quota",
+			class: aiagent.LLMErrorQuota},
+		{name: "auth", status: http.StatusUnauthorized, code: "invalid_api_key", class: aiagent.LLMErrorAuth},
+		{name: "forbidden", status: http.StatusForbidden, class: aiagent.LLMErrorAuth},
+		{name: "context length", status: http.StatusBadRequest, code: "context_length_exceeded",
+			class: aiagent.LLMErrorContextLength},
+		{name: "invalid request", status: http.StatusBadRequest, code: "invalid_value",
+			class: aiagent.LLMErrorInvalidRequest},
+		{name: "not found", status: http.StatusNotFound, code: "model_not_found",
+			class: aiagent.LLMErrorInvalidRequest},
+		{name: "server", status: http.StatusInternalServerError, class: aiagent.LLMErrorServer},
+		{name: "overloaded", status: http.StatusServiceUnavailable, class: aiagent.LLMErrorServer},
+		{name: "conflict", status: http.StatusConflict, class: aiagent.LLMErrorServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newServerLLM(t, failWith(tt.status, tt.code, "failed", nil))
+
+			llmErr := callError(t, llm)
+			if llmErr.Class != tt.class || llmErr.StatusCode != tt.status {
+				t.Errorf("error = %s %d, want %s %d", llmErr.Class, llmErr.StatusCode, tt.class, tt.status)
+			}
+		})
+	}
+}
+
+func TestErrorRetryAfterFromMessage(t *testing.T) {
+	llm := newServerLLM(t, failWith(http.StatusTooManyRequests, "rate_limit_exceeded",
+		"Rate limit reached for gpt-4o. Please try again in 1.5s.", nil))
+
+	if got := callError(t, llm).RetryAfter; got != 1500*time.Millisecond {
+		t.Errorf("retry after = %v, want 1.5s", got)
+	}
+}
+
+func TestErrorRetryAfterFromHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "seconds", header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": {"250"}}, want: 250 * time.Millisecond},
+		{name: "none", header: http.Header{}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(failWith(http.StatusServiceUnavailable, "", "overloaded", tt.header))
+			t.Cleanup(srv.Close)
+
+			cfg := goopenai.DefaultConfig("test-key")
+			cfg.BaseURL = srv.URL + "/v1"
+			cfg.HTTPClient = &http.Client{Transport: openai.NewRetryAfterTransport(nil)}
+			llm := openai.MustNewLLM(cfg, openai.ModelGPT4o)
+
+			if got := callError(t, llm).RetryAfter; got != tt.want {
+				t.Errorf("retry after = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	cfg := goopenai.DefaultConfig("test-key")
+	cfg.BaseURL = srv.URL + "/v1"
+	llm := openai.MustNewLLM(cfg, openai.ModelGPT4o)
+
+	if got := callError(t, llm).Class; got != aiagent.LLMErrorNetwork {
+		t.Errorf("class = %s, want %s", got, aiagent.LLMErrorNetwork)
+	}
+}
+
+func TestErrorCancelledIsNotClassified(t *testing.T) {
+	llm := newServerLLM(t, failWith(http.StatusInternalServerError, "", "failed", nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := llm.Call(ctx, []aiagent.Message{aiagent.NewUserMessage("hi")})
+	var llmErr *aiagent.LLMError
+	if !errors.Is(err, context.Canceled) || errors.As(err, &llmErr) {
+		t.Errorf("err = %v, want a plain %v", err, context.Canceled)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
 
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/wintermonth2298/agentus/aiagent"
@@ -13,41 +16,191 @@ import (
 type LLM struct {
 	client *openai.Client
 	model  string
+	info   Model
 	tools  []openai.Tool
 }
 
-func NewLLM(client *openai.Client, model Model) *LLM {
+type Option func(*options)
+
+type options struct {
+	registry *Registry
+}
+
+// WithRegistry looks the model up in r instead of DefaultRegistry.
+func WithRegistry(r *Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// ErrInvalidToolArgs is returned for a response whose tool call arguments
+// are not valid JSON, e.g. because it was cut off at the token limit.
+var ErrInvalidToolArgs = errors.New("invalid tool call arguments")
+
+// NewLLM returns an LLM calling the named model, which must be registered.
+// Its client is built from cfg, with cfg.HTTPClient wrapped to send the
+// generation parameters go-openai leaves out when they are zero.
+func NewLLM(cfg openai.ClientConfig, model string, opts ...Option) (*LLM, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.registry == nil {
+		o.registry = DefaultRegistry()
+	}
+
+	info, ok := o.registry.Lookup(model)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, model)
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	cfg.HTTPClient = samplingDoer{wrapped: cfg.HTTPClient}
+
 	return &LLM{
-		client: client,
-		model:  mapModel(model),
+		client: openai.NewClientWithConfig(cfg),
+		model:  model,
+		info:   info,
+	}, nil
+}
+
+func MustNewLLM(cfg openai.ClientConfig, model string, opts ...Option) *LLM {
+	llm, err := NewLLM(cfg, model, opts...)
+	if err != nil {
+		panic(err)
 	}
+
+	return llm
+}
+
+// Model returns the registry entry of the model.
+func (a *LLM) Model() Model {
+	return a.info
+}
+
+// ModelName returns the name the model is called by, which may be
+// a snapshot of the registry entry returned by Model.
+func (a *LLM) ModelName() string {
+	return a.model
 }
 
 func (a *LLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
-	req := newChatCompletionRequest(a.model, a.tools, history)
+	req, err := a.newRequest(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
 
+	ctx = withSampling(ctx, newSampling(aiagent.CallConfigFrom(ctx).Generation))
+	ctx, retryAfter := withRetryAfterSlot(ctx)
 	resp, err := a.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return aiagent.Message{}, fmt.Errorf("openai api call: %w", err)
+		return aiagent.Message{}, classifyError(fmt.Errorf("openai api call: %w", err), retryAfter.get())
 	}
 
-	return parseResponse(resp.Choices[0].Message), nil
+	choice := resp.Choices[0]
+	msg, err := parseResponse(choice.Message, choice.FinishReason)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	msg = msg.WithUsage(mapUsage(resp.Usage))
+
+	return msg.WithMeta(aiagent.MetaModel, resp.Model), nil
 }
 
 func (a *LLM) RegisterTool(tool aiagent.Tool) {
 	a.tools = append(a.tools, mapToolSpecs(tool))
 }
 
-func newChatCompletionRequest(
-	model string,
-	tools []openai.Tool,
-	history []aiagent.Message,
-) openai.ChatCompletionRequest {
-	return openai.ChatCompletionRequest{
-		Model:    model,
-		Tools:    tools,
+func (a *LLM) newRequest(ctx context.Context, history []aiagent.Message) (openai.ChatCompletionRequest, error) {
+	if len(a.tools) > 0 && !a.info.Tools {
+		return openai.ChatCompletionRequest{}, fmt.Errorf("tools: %w %s", ErrUnsupported, a.model)
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:    a.model,
+		Tools:    a.tools,
 		Messages: mapChat(history),
 	}
+
+	cfg := aiagent.CallConfigFrom(ctx)
+	a.mapGeneration(&req, cfg.Generation)
+	// tool_choice must not be sent without tools
+	if len(a.tools) > 0 {
+		req.ToolChoice = mapToolChoice(cfg.ToolChoice)
+	}
+	// models without structured output rely on the prompt SendTyped adds
+	if cfg.ResponseFormat != nil && a.info.StructuredOutput {
+		format, err := mapResponseFormat(*cfg.ResponseFormat)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, err
+		}
+		req.ResponseFormat = format
+	}
+
+	return req, nil
+}
+
+// mapGeneration sets the parameters go-openai's request can express,
+// the sampling parameters are sent by samplingDoer.
+func (a *LLM) mapGeneration(req *openai.ChatCompletionRequest, g aiagent.GenerationConfig) {
+	req.MaxCompletionTokens = g.MaxTokens
+	req.Seed = g.Seed
+	req.Stop = g.Stop
+	// other models reject the parameter
+	if g.ReasoningEffort != aiagent.ReasoningEffortDefault && a.info.ReasoningEffort {
+		req.ReasoningEffort = g.ReasoningEffort.String()
+	}
+}
+
+func mapToolChoice(c aiagent.ToolChoice) any {
+	switch c.Mode {
+	case aiagent.ToolChoiceAuto:
+		// auto is the default
+		return nil
+	case aiagent.ToolChoiceNone:
+		return "none"
+	case aiagent.ToolChoiceRequired:
+		return "required"
+	case aiagent.ToolChoiceTool:
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: c.Tool},
+		}
+	}
+	return nil
+}
+
+func mapResponseFormat(f aiagent.ResponseFormat) (*openai.ChatCompletionResponseFormat, error) {
+	schema, err := buildSchema(f.Params)
+	if err != nil {
+		return nil, fmt.Errorf("build response schema: %w", err)
+	}
+
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   schemaName(f.Name),
+			Schema: schema,
+			Strict: false,
+		},
+	}, nil
+}
+
+// schemaName turns name into one accepted by the api, which allows
+// letters, digits, underscores and dashes.
+func schemaName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" {
+		return "response"
+	}
+	return name
 }
 
 func mapChat(history []aiagent.Message) []openai.ChatCompletionMessage {
@@ -99,18 +252,6 @@ func mapToolSpecs(tool aiagent.Tool) openai.Tool {
 	}
 }
 
-func mapModel(model Model) string {
-	switch model {
-	case ModelGPT3Dot5Turbo0125:
-		return openai.GPT3Dot5Turbo0125
-	case ModelGPT4o:
-		return openai.GPT4o
-	}
-
-	// TODO: log fallback functionality
-	return openai.GPT3Dot5Turbo0125
-}
-
 func mapMessage(m aiagent.Message) (openai.ChatCompletionMessage, error) {
 	role := mapRole(m.Type())
 
@@ -129,8 +270,10 @@ func mapMessage(m aiagent.Message) (openai.ChatCompletionMessage, error) {
 		}, nil
 	case aiagent.MessageTypeToolRequest:
 		tcRequests := m.MustToolCallRequests()
+		text, _ := m.Text()
 		return openai.ChatCompletionMessage{
 			Role:      role,
+			Content:   text,
 			ToolCalls: mapToolCalls(tcRequests),
 		}, nil
 	}
@@ -157,22 +300,45 @@ func mapToolCall(req aiagent.ToolCallRequest) openai.ToolCall {
 	}
 }
 
-func parseResponse(m openai.ChatCompletionMessage) aiagent.Message {
+func parseResponse(m openai.ChatCompletionMessage, finish openai.FinishReason) (aiagent.Message, error) {
 	if len(m.ToolCalls) > 0 {
 		tcRequests := make([]aiagent.ToolCallRequest, 0, len(m.ToolCalls))
 		for _, tc := range m.ToolCalls {
-			tcRequests = append(tcRequests, parseToolCallRequest(tc))
+			req, err := parseToolCallRequest(tc)
+			if err != nil {
+				return aiagent.Message{}, fmt.Errorf("%w (finish reason %q)", err, finish)
+			}
+			tcRequests = append(tcRequests, req)
 		}
-		return aiagent.NewToolCallRequestMessage(tcRequests)
+		if m.Content != "" {
+			return aiagent.NewToolCallRequestMessageWithText(m.Content, tcRequests), nil
+		}
+		return aiagent.NewToolCallRequestMessage(tcRequests), nil
+	}
+
+	return aiagent.NewAssistantMessage(m.Content), nil
+}
+
+func mapUsage(u openai.Usage) aiagent.Usage {
+	usage := aiagent.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if u.PromptTokensDetails != nil {
+		usage.CachedTokens = u.PromptTokensDetails.CachedTokens
+	}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
 	}
 
-	return aiagent.NewAssistantMessage(m.Content)
+	return usage
 }
 
-func parseToolCallRequest(tc openai.ToolCall) aiagent.ToolCallRequest {
+func parseToolCallRequest(tc openai.ToolCall) (aiagent.ToolCallRequest, error) {
 	var args json.RawMessage
 	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-		panic("invalid tool arguments: " + err.Error())
+		return aiagent.ToolCallRequest{}, fmt.Errorf("%w of %s: %w", ErrInvalidToolArgs, tc.Function.Name, err)
 	}
 
 	return aiagent.ToolCallRequest{
@@ -181,5 +347,5 @@ func parseToolCallRequest(tc openai.ToolCall) aiagent.ToolCallRequest {
 			Name: tc.Function.Name,
 		},
 		Args: args,
-	}
+	}, nil
 }
@@ -0,0 +1,208 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/llms/openai"
+)
+
+const helloResponse = `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hello"}}]}`
+
+// reply answers a chat completion with body.
+func reply(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+// capture decodes every request body into fields and answers with hello.
+func capture(t *testing.T, fields *map[string]json.RawMessage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*fields = nil
+		if err := json.NewDecoder(r.Body).Decode(fields); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reply(helloResponse)(w, r)
+	}
+}
+
+// callWith calls llm with cfg in the context.
+func callWith(t *testing.T, llm aiagent.LLM, cfg aiagent.CallConfig) {
+	t.Helper()
+
+	ctx := aiagent.WithCallConfig(context.Background(), cfg)
+	if _, err := llm.Call(ctx, []aiagent.Message{aiagent.NewUserMessage("hi")}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+}
+
+func TestCallKeepsTextWithToolCalls(t *testing.T) {
+	llm := newServerLLM(t, reply(`{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant",`+
+		`"content":"Checking the weather.","tool_calls":[{"id":"call_a","type":"function",`+
+		`"function":{"name":"weather","arguments":"{\"city\":\"Oslo\"}"}}]}}]}`))
+
+	msg, err := llm.Call(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	reqs, err := msg.ToolCallRequests()
+	if err != nil || len(reqs) != 1 || reqs[0].Call.Name != "weather" {
+		t.Fatalf("tool calls = %+v, %v, want one weather call", reqs, err)
+	}
+	if text, _ := msg.Text(); text != "Checking the weather." {
+		t.Errorf("text = %q, want the content sent with the calls", text)
+	}
+}
+
+func TestCallInvalidToolArgs(t *testing.T) {
+	llm := newServerLLM(t, reply(`{"model":"gpt-4o","choices":[{"index":0,"finish_reason":"length",`+
+		`"message":{"role":"assistant","tool_calls":[{"id":"call_a","type":"function",`+
+		`"function":{"name":"weather","arguments":"{\"city\":\"Os"}}]}}]}`))
+
+	_, err := llm.Call(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")})
+	if !errors.Is(err, openai.ErrInvalidToolArgs) {
+		t.Errorf("err = %v, want %v", err, openai.ErrInvalidToolArgs)
+	}
+}
+
+func TestCallSendsSampling(t *testing.T) {
+	zero, half := 0.0, 0.5
+	tests := []struct {
+		name string
+		gen  aiagent.GenerationConfig
+		want map[string]string
+	}{
+		{
+			name: "unset",
+			want: map[string]string{"temperature": "", "top_p": "", "presence_penalty": "", "frequency_penalty": ""},
+		},
+		{
+			name: "zero",
+			gen:  aiagent.GenerationConfig{Temperature: &zero, PresencePenalty: &zero},
+			want: map[string]string{"temperature": "0", "presence_penalty": "0", "top_p": ""},
+		},
+		{
+			name: "set",
+			gen:  aiagent.GenerationConfig{TopP: &half, FrequencyPenalty: &half},
+			want: map[string]string{"top_p": "0.5", "frequency_penalty": "0.5", "temperature": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			llm := newServerLLM(t, capture(t, &fields))
+
+			callWith(t, llm, aiagent.CallConfig{Generation: tt.gen})
+			for key, want := range tt.want {
+				if got := string(fields[key]); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if string(fields["model"]) != `"gpt-4o"` {
+				t.Errorf("model = %s, want the rest of the request kept", fields["model"])
+			}
+		})
+	}
+}
+
+func TestCallReasoningEffort(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{model: openai.ModelO4Mini, want: `"high"`},
+		{model: openai.ModelGPT4o, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			llm := newModelServerLLM(t, tt.model, capture(t, &fields))
+
+			callWith(t, llm, aiagent.CallConfig{
+				Generation: aiagent.GenerationConfig{ReasoningEffort: aiagent.ReasoningEffortHigh},
+			})
+			if got := string(fields["reasoning_effort"]); got != tt.want {
+				t.Errorf("reasoning_effort = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallToolChoice(t *testing.T) {
+	tests := []struct {
+		name   string
+		choice aiagent.ToolChoice
+		want   string
+	}{
+		{name: "auto", choice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceAuto}, want: ""},
+		{name: "none", choice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceNone}, want: `"none"`},
+		{name: "required", choice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceRequired}, want: `"required"`},
+		{
+			name:   "tool",
+			choice: aiagent.ForceTool("weather"),
+			want:   `{"type":"function","function":{"name":"weather"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			llm := newServerLLM(t, capture(t, &fields))
+			llm.RegisterTool(aiagent.MustNewDerivedTool("weather", "tells the weather",
+				func(context.Context, struct{}) (string, error) { return "sunny", nil }))
+
+			callWith(t, llm, aiagent.CallConfig{ToolChoice: tt.choice})
+			if got := string(fields["tool_choice"]); got != tt.want {
+				t.Errorf("tool_choice = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallWithoutToolsOmitsToolChoice(t *testing.T) {
+	var fields map[string]json.RawMessage
+	llm := newServerLLM(t, capture(t, &fields))
+
+	callWith(t, llm, aiagent.CallConfig{ToolChoice: aiagent.ToolChoice{Mode: aiagent.ToolChoiceRequired}})
+	if _, ok := fields["tool_choice"]; ok {
+		t.Errorf("tool_choice = %s, want it omitted without tools", fields["tool_choice"])
+	}
+	if _, ok := fields["tools"]; ok {
+		t.Errorf("tools = %s, want them omitted", fields["tools"])
+	}
+}
+
+func TestCallRawJSONParamHasNoType(t *testing.T) {
+	type args struct {
+		Query string          `json:"query"`
+		Extra json.RawMessage `json:"extra"`
+	}
+	var fields map[string]json.RawMessage
+	llm := newServerLLM(t, capture(t, &fields))
+	llm.RegisterTool(aiagent.MustNewDerivedTool("search", "searches",
+		func(context.Context, args) (string, error) { return "found", nil }))
+
+	callWith(t, llm, aiagent.CallConfig{})
+	var tools []struct {
+		Function struct {
+			Parameters struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"parameters"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(fields["tools"], &tools); err != nil || len(tools) != 1 {
+		t.Fatalf("tools = %s, %v, want one tool", fields["tools"], err)
+	}
+	props := tools[0].Function.Parameters.Properties
+	if props["query"]["type"] != "string" {
+		t.Errorf("query schema = %v, want a string", props["query"])
+	}
+	if typ, ok := props["extra"]["type"]; ok {
+		t.Errorf("extra type = %v, want none for any JSON value", typ)
+	}
+}
@@ -1,8 +1,147 @@
 package openai
 
-type Model uint
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
 
 const (
-	ModelGPT3Dot5Turbo0125 Model = iota
-	ModelGPT4o
+	ModelGPT3Dot5Turbo0125 = "gpt-3.5-turbo-0125"
+	ModelGPT4o             = "gpt-4o"
+	ModelGPT4oMini         = "gpt-4o-mini"
+	ModelGPT4Dot1          = "gpt-4.1"
+	ModelGPT4Dot1Mini      = "gpt-4.1-mini"
+	ModelGPT4Dot1Nano      = "gpt-4.1-nano"
+	ModelO3                = "o3"
+	ModelO4Mini            = "o4-mini"
 )
+
+var (
+	ErrUnknownModel = errors.New("unknown model")
+	ErrUnsupported  = errors.New("not supported by model")
+)
+
+// Model describes the capabilities and price of a chat model.
+type Model struct {
+	Name string
+	// ContextWindow is the number of tokens of prompt and output together.
+	ContextWindow int
+	MaxOutput     int
+
+	Tools            bool
+	Vision           bool
+	StructuredOutput bool
+	// ReasoningEffort is set for reasoning models, which accept
+	// GenerationConfig.ReasoningEffort.
+	ReasoningEffort bool
+
+	Price aiagent.Price
+}
+
+// Registry maps model names to models. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]Model
+}
+
+func NewRegistry(models ...Model) *Registry {
+	r := &Registry{models: make(map[string]Model, len(models))}
+	for _, m := range models {
+		r.models[m.Name] = m
+	}
+
+	return r
+}
+
+// DefaultRegistry returns a new registry of OpenAI models with list prices
+// in USD. Register models on it to add or override them.
+func DefaultRegistry() *Registry {
+	return NewRegistry(
+		Model{
+			Name: ModelGPT3Dot5Turbo0125, ContextWindow: 16_385, MaxOutput: 4_096,
+			Tools: true,
+			Price: aiagent.Price{Input: 0.5, Output: 1.5},
+		},
+		Model{
+			Name: ModelGPT4o, ContextWindow: 128_000, MaxOutput: 16_384,
+			Tools: true, Vision: true, StructuredOutput: true,
+			Price: aiagent.Price{Input: 2.5, CachedInput: 1.25, Output: 10},
+		},
+		Model{
+			Name: ModelGPT4oMini, ContextWindow: 128_000, MaxOutput: 16_384,
+			Tools: true, Vision: true, StructuredOutput: true,
+			Price: aiagent.Price{Input: 0.15, CachedInput: 0.075, Output: 0.6},
+		},
+		Model{
+			Name: ModelGPT4Dot1, ContextWindow: 1_047_576, MaxOutput: 32_768,
+			Tools: true, Vision: true, StructuredOutput: true,
+			Price: aiagent.Price{Input: 2, CachedInput: 0.5, Output: 8},
+		},
+		Model{
+			Name: ModelGPT4Dot1Mini, ContextWindow: 1_047_576, MaxOutput: 32_768,
+			Tools: true, Vision: true, StructuredOutput: true,
+			Price: aiagent.Price{Input: 0.4, CachedInput: 0.1, Output: 1.6},
+		},
+		Model{
+			Name: ModelGPT4Dot1Nano, ContextWindow: 1_047_576, MaxOutput: 32_768,
+			Tools: true, Vision: true, StructuredOutput: true,
+			Price: aiagent.Price{Input: 0.1, CachedInput: 0.025, Output: 0.4},
+		},
+		Model{
+			Name: ModelO3, ContextWindow: 200_000, MaxOutput: 100_000,
+			Tools: true, Vision: true, StructuredOutput: true, ReasoningEffort: true,
+			Price: aiagent.Price{Input: 2, CachedInput: 0.5, Output: 8},
+		},
+		Model{
+			Name: ModelO4Mini, ContextWindow: 200_000, MaxOutput: 100_000,
+			Tools: true, Vision: true, StructuredOutput: true, ReasoningEffort: true,
+			Price: aiagent.Price{Input: 1.1, CachedInput: 0.275, Output: 4.4},
+		},
+	)
+}
+
+// Register adds m, replacing a model of the same name.
+func (r *Registry) Register(m Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.models[m.Name] = m
+}
+
+// Lookup finds a model by name. Snapshots such as "gpt-4o-2024-08-06"
+// match their base model unless they are registered themselves,
+// see aiagent.LookupModel.
+func (r *Registry) Lookup(name string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return aiagent.LookupModel(r.models, name)
+}
+
+// Models returns the registered models sorted by name.
+func (r *Registry) Models() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := make([]Model, 0, len(r.models))
+	for _, name := range slices.Sorted(maps.Keys(r.models)) {
+		models = append(models, r.models[name])
+	}
+	return models
+}
+
+// Pricing returns the prices of the registered models for aiagent.Pricing.Cost.
+func (r *Registry) Pricing() aiagent.Pricing {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p := make(aiagent.Pricing, len(r.models))
+	for name, m := range r.models {
+		p[name] = m.Price
+	}
+	return p
+}
@@ -0,0 +1,107 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// sampling holds the generation parameters that go-openai's request leaves
+// out when they are zero. samplingDoer writes them into the request body,
+// so an explicit zero reaches the api and an unset parameter is not sent.
+type sampling struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+}
+
+func newSampling(g aiagent.GenerationConfig) sampling {
+	return sampling{
+		Temperature:      toFloat32(g.Temperature),
+		TopP:             toFloat32(g.TopP),
+		PresencePenalty:  toFloat32(g.PresencePenalty),
+		FrequencyPenalty: toFloat32(g.FrequencyPenalty),
+	}
+}
+
+func (s sampling) empty() bool {
+	return s == sampling{}
+}
+
+// write sets the parameters of s in the JSON object body.
+func (s sampling) write(body []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("decode request body: %w", err)
+	}
+
+	params, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("encode sampling: %w", err)
+	}
+	// unmarshaling into the map adds the parameters to the request fields
+	if err = json.Unmarshal(params, &fields); err != nil {
+		return nil, fmt.Errorf("encode sampling: %w", err)
+	}
+
+	body, err = json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("encode request body: %w", err)
+	}
+	return body, nil
+}
+
+func toFloat32(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	f := float32(*v)
+	return &f
+}
+
+type samplingKey struct{}
+
+func withSampling(ctx context.Context, s sampling) context.Context {
+	if s.empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, samplingKey{}, s)
+}
+
+// samplingDoer is the http client of the LLM's openai client.
+type samplingDoer struct {
+	wrapped openai.HTTPDoer
+}
+
+func (d samplingDoer) Do(req *http.Request) (*http.Response, error) {
+	s, ok := req.Context().Value(samplingKey{}).(sampling)
+	if !ok || req.Body == nil {
+		return d.wrapped.Do(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	body, err = s.write(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+
+	return d.wrapped.Do(req)
+}
@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+func (a *LLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	req, err := a.newRequest(ctx, history)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	ctx = withSampling(ctx, newSampling(aiagent.CallConfigFrom(ctx).Generation))
+	ctx, retryAfter := withRetryAfterSlot(ctx)
+	stream, err := a.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return aiagent.Message{}, classifyError(fmt.Errorf("openai api stream: %w", err), retryAfter.get())
+	}
+	defer stream.Close()
+
+	var acc streamAccumulator
+	for {
+		chunk, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			break
+		}
+		if errRecv != nil {
+			return aiagent.Message{}, classifyError(fmt.Errorf("openai stream recv: %w", errRecv), 0)
+		}
+		if chunk.Usage != nil {
+			acc.usage = *chunk.Usage
+		}
+		if chunk.Model != "" {
+			acc.model = chunk.Model
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			acc.finish = reason
+		}
+
+		for _, d := range acc.add(chunk.Choices[0].Delta) {
+			onDelta(d)
+		}
+	}
+
+	msg, err := parseResponse(acc.message(), acc.finish)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+	msg = msg.WithUsage(mapUsage(acc.usage))
+
+	return msg.WithMeta(aiagent.MetaModel, acc.model), nil
+}
+
+type streamAccumulator struct {
+	content   strings.Builder
+	toolCalls []*streamToolCall
+	usage     openai.Usage
+	model     string
+	finish    openai.FinishReason
+}
+
+type streamToolCall struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+func (s *streamAccumulator) add(d openai.ChatCompletionStreamChoiceDelta) []aiagent.Delta {
+	deltas := make([]aiagent.Delta, 0, 1+len(d.ToolCalls))
+
+	if d.Content != "" {
+		s.content.WriteString(d.Content)
+		deltas = append(deltas, aiagent.Delta{Text: d.Content})
+	}
+
+	for _, tc := range d.ToolCalls {
+		idx := len(s.toolCalls)
+		if tc.Index != nil {
+			idx = *tc.Index
+		}
+		for len(s.toolCalls) <= idx {
+			s.toolCalls = append(s.toolCalls, &streamToolCall{})
+		}
+
+		call := s.toolCalls[idx]
+		if tc.ID != "" {
+			call.id = tc.ID
+		}
+		if tc.Function.Name != "" {
+			call.name = tc.Function.Name
+		}
+		call.args.WriteString(tc.Function.Arguments)
+
+		deltas = append(deltas, aiagent.Delta{ToolCall: &aiagent.ToolCallDelta{
+			Index: idx,
+			ID:    call.id,
+			Name:  call.name,
+			Args:  call.args.String(),
+		}})
+	}
+
+	return deltas
+}
+
+func (s *streamAccumulator) message() openai.ChatCompletionMessage {
+	msg := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: s.content.String(),
+	}
+	for _, tc := range s.toolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:   tc.id,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      tc.name,
+				Arguments: tc.args.String(),
+			},
+		})
+	}
+
+	return msg
+}
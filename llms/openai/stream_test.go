@@ -0,0 +1,161 @@
+package openai_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goopenai "github.com/sashabaranov/go-openai"
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/llms/openai"
+)
+
+// newServerLLM returns an LLM for gpt-4o talking to a test server that
+// answers chat completions with handler.
+func newServerLLM(t *testing.T, handler http.HandlerFunc) *openai.LLM {
+	t.Helper()
+
+	return newModelServerLLM(t, openai.ModelGPT4o, handler)
+}
+
+func newModelServerLLM(t *testing.T, model string, handler http.HandlerFunc) *openai.LLM {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := goopenai.DefaultConfig("test-key")
+	cfg.BaseURL = srv.URL + "/v1"
+
+	return openai.MustNewLLM(cfg, model)
+}
+
+// sse answers with one server-sent event per data line.
+func sse(lines ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, l := range lines {
+			fmt.Fprintf(w, "data: %s\n\n", l)
+		}
+	}
+}
+
+func toolCallChunk(index int, id string, name string, args string) string {
+	return fmt.Sprintf(`{"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[`+
+		`{"index":%d,"id":%q,"type":"function","function":{"name":%q,"arguments":%q}}]}}]}`,
+		index, id, name, args)
+}
+
+func TestStreamAssemblesToolCalls(t *testing.T) {
+	llm := newServerLLM(t, sse(
+		toolCallChunk(0, "call_a", "weather", ""),
+		toolCallChunk(0, "", "", `{"ci`),
+		toolCallChunk(1, "call_b", "time", `{"zone":`),
+		toolCallChunk(0, "", "", `ty":"Oslo"}`),
+		toolCallChunk(1, "", "", `"CET"}`),
+		`{"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":6,"total_tokens":16}}`,
+		`[DONE]`,
+	))
+
+	var deltas []aiagent.Delta
+	msg, err := llm.Stream(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")},
+		func(d aiagent.Delta) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	reqs, err := msg.ToolCallRequests()
+	if err != nil {
+		t.Fatalf("tool call requests: %v", err)
+	}
+	want := []struct{ id, name, args string }{
+		{"call_a", "weather", `{"city":"Oslo"}`},
+		{"call_b", "time", `{"zone":"CET"}`},
+	}
+	if len(reqs) != len(want) {
+		t.Fatalf("got %d tool calls, want %d", len(reqs), len(want))
+	}
+	for i, w := range want {
+		got := reqs[i]
+		if got.Call.ID != w.id || got.Call.Name != w.name || string(got.Args) != w.args {
+			t.Errorf("call %d = %s %s %s, want %s %s %s",
+				i, got.Call.ID, got.Call.Name, got.Args, w.id, w.name, w.args)
+		}
+	}
+
+	if len(deltas) != 5 {
+		t.Fatalf("got %d deltas, want 5", len(deltas))
+	}
+	last := deltas[len(deltas)-1].ToolCall
+	if last == nil || last.Index != 1 || last.ID != "call_b" || last.Args != `{"zone":"CET"}` {
+		t.Errorf("last delta = %+v, want the assembled second call", last)
+	}
+	if u := msg.Usage(); u.TotalTokens != 16 {
+		t.Errorf("usage = %+v, want 16 total tokens", u)
+	}
+	if model, _ := msg.Meta(aiagent.MetaModel); model != "gpt-4o" {
+		t.Errorf("model = %q, want gpt-4o", model)
+	}
+}
+
+func TestStreamText(t *testing.T) {
+	llm := newServerLLM(t, sse(
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`[DONE]`,
+	))
+
+	var text strings.Builder
+	msg, err := llm.Stream(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")},
+		func(d aiagent.Delta) { text.WriteString(d.Text) })
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if got := msg.MustText(); got != "Hello" {
+		t.Errorf("text = %q, want %q", got, "Hello")
+	}
+	if text.String() != "Hello" {
+		t.Errorf("deltas = %q, want %q", text.String(), "Hello")
+	}
+}
+
+func TestStreamEarlyError(t *testing.T) {
+	llm := newServerLLM(t, sse(
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"partial"}}]}`,
+		`{"error":{"message":"The server had an error","type":"server_error"}}`,
+	))
+
+	var deltas []aiagent.Delta
+	_, err := llm.Stream(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")},
+		func(d aiagent.Delta) { deltas = append(deltas, d) })
+	if err == nil {
+		t.Fatal("stream succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "The server had an error") {
+		t.Errorf("err = %v, want the server message", err)
+	}
+	if len(deltas) != 1 || deltas[0].Text != "partial" {
+		t.Errorf("deltas = %+v, want the partial text only", deltas)
+	}
+}
+
+func TestStreamInvalidToolArgs(t *testing.T) {
+	llm := newServerLLM(t, sse(
+		toolCallChunk(0, "call_a", "weather", `{"city":"Os`),
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`,
+		`[DONE]`,
+	))
+
+	_, err := llm.Stream(context.Background(), []aiagent.Message{aiagent.NewUserMessage("hi")},
+		func(aiagent.Delta) {})
+	if !errors.Is(err, openai.ErrInvalidToolArgs) {
+		t.Fatalf("err = %v, want %v", err, openai.ErrInvalidToolArgs)
+	}
+	if !strings.Contains(err.Error(), "length") {
+		t.Errorf("err = %v, want the finish reason", err)
+	}
+}
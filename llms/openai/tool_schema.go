@@ -42,12 +42,21 @@ func requiredFromSlice(params []aiagent.Param) []string {
 
 func toParamSchema(p aiagent.Param) jsonToolSchema {
 	s := jsonToolSchema{
-		"type":        typeOf(p.Type),
 		"description": p.Description,
 	}
+	// a value of any type has no type constraint
+	if t := typeOf(p.Type); t != "" {
+		s["type"] = t
+	}
 	if len(p.Enum) > 0 {
 		s["enum"] = p.Enum
 	}
+	if p.Minimum != nil {
+		s["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		s["maximum"] = *p.Maximum
+	}
 
 	//nolint:exhaustive // no additional fields for scalar types
 	switch p.Type {
@@ -57,6 +66,9 @@ func toParamSchema(p aiagent.Param) jsonToolSchema {
 		s["properties"] = propsFromMap(p.Properties)
 		s["additionalProperties"] = false
 		s["required"] = requiredFromMap(p.Properties)
+		if p.Values != nil {
+			s["additionalProperties"] = toParamSchema(*p.Values)
+		}
 	}
 
 	return s
@@ -99,6 +111,8 @@ func typeOf(pt aiagent.ParamType) string {
 		return "object"
 	case aiagent.ParamTypeArray:
 		return "array"
+	case aiagent.ParamTypeAny:
+		return ""
 	default:
 		panic(fmt.Sprintf("unknown param type: %v", pt))
 	}
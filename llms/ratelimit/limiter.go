@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter enforces requests-per-minute and tokens-per-minute budgets with
+// token buckets. It is safe for concurrent use and meant to be shared by all
+// LLMs that use the same API key. Waiting callers are served in arrival order.
+type Limiter struct {
+	// turn admits one waiting caller at a time; blocked senders on a channel
+	// are woken in FIFO order, which keeps the limiter fair.
+	turn chan struct{}
+
+	mu       sync.Mutex
+	requests bucket
+	tokens   bucket
+}
+
+// NewLimiter returns a limiter allowing rpm requests and tpm tokens per minute.
+// A zero limit is not enforced.
+func NewLimiter(rpm int, tpm int) *Limiter {
+	now := time.Now()
+	return &Limiter{
+		turn:     make(chan struct{}, 1),
+		requests: newBucket(rpm, now),
+		tokens:   newBucket(tpm, now),
+	}
+}
+
+// Wait blocks until one request with the estimated number of tokens fits
+// into the budgets and takes it from them. It returns the number of tokens
+// taken, which is capped at the token budget and has to be passed to
+// Reconcile.
+func (l *Limiter) Wait(ctx context.Context, tokens int) (int, error) {
+	select {
+	case l.turn <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	defer func() { <-l.turn }()
+
+	for {
+		taken, delay := l.take(tokens)
+		if delay == 0 {
+			return taken, nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// Reconcile corrects the token budget once the actual usage of a request
+// is known. taken is the number of tokens returned by Wait.
+func (l *Limiter) Reconcile(taken int, actual int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens.level += float64(taken - actual)
+	l.tokens.level = min(l.tokens.level, l.tokens.capacity)
+}
+
+// take consumes the budgets if they suffice and returns the tokens taken,
+// otherwise it returns how long to wait before trying again.
+func (l *Limiter) take(tokens int) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.requests.refill(now)
+	l.tokens.refill(now)
+
+	need := tokens
+	if !l.tokens.unlimited() {
+		// a request larger than the whole budget would never fit
+		need = min(need, int(l.tokens.capacity))
+	}
+	delay := max(l.requests.wait(1), l.tokens.wait(float64(need)))
+	if delay > 0 {
+		return 0, delay
+	}
+
+	l.requests.take(1)
+	l.tokens.take(float64(need))
+	return need, 0
+}
+
+type bucket struct {
+	capacity  float64
+	level     float64
+	perSecond float64
+	last      time.Time
+}
+
+func newBucket(perMinute int, now time.Time) bucket {
+	return bucket{
+		capacity:  float64(perMinute),
+		level:     float64(perMinute),
+		perSecond: float64(perMinute) / time.Minute.Seconds(),
+		last:      now,
+	}
+}
+
+func (b *bucket) unlimited() bool {
+	return b.capacity <= 0
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.unlimited() {
+		return
+	}
+	b.level = min(b.capacity, b.level+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+}
+
+func (b *bucket) wait(need float64) time.Duration {
+	if b.unlimited() || b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.perSecond * float64(time.Second))
+}
+
+func (b *bucket) take(n float64) {
+	if !b.unlimited() {
+		b.level -= n
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+// LLM waits for its limiter before every call of the wrapped LLM. The prompt
+// size is estimated up front and reconciled with the reported usage afterwards.
+type LLM struct {
+	llm     aiagent.LLM
+	limiter *Limiter
+	count   func(aiagent.Message) int
+}
+
+type Option func(*LLM)
+
+func New(llm aiagent.LLM, limiter *Limiter, opts ...Option) *LLM {
+	l := &LLM{
+		llm:     llm,
+		limiter: limiter,
+		count:   aiagent.EstimateTokens,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithTokenCounter replaces aiagent.EstimateTokens for estimating prompts.
+func WithTokenCounter(count func(aiagent.Message) int) Option {
+	return func(l *LLM) {
+		l.count = count
+	}
+}
+
+func (l *LLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	return l.do(ctx, history, func() (aiagent.Message, error) {
+		return l.llm.Call(ctx, history)
+	})
+}
+
+func (l *LLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	return l.do(ctx, history, func() (aiagent.Message, error) {
+		return aiagent.StreamCall(ctx, l.llm, history, onDelta)
+	})
+}
+
+func (l *LLM) RegisterTool(tool aiagent.Tool) {
+	l.llm.RegisterTool(tool)
+}
+
+func (l *LLM) ModelName() string {
+	return aiagent.ModelName(l.llm)
+}
+
+func (l *LLM) do(
+	ctx context.Context,
+	history []aiagent.Message,
+	call func() (aiagent.Message, error),
+) (aiagent.Message, error) {
+	estimated := 0
+	for _, m := range history {
+		estimated += l.count(m)
+	}
+
+	taken, err := l.limiter.Wait(ctx, estimated)
+	if err != nil {
+		return aiagent.Message{}, err
+	}
+
+	resp, err := call()
+	if err != nil {
+		// failed requests count against the request budget only
+		l.limiter.Reconcile(taken, 0)
+		return aiagent.Message{}, err
+	}
+
+	if used := resp.Usage().TotalTokens; used > 0 {
+		l.limiter.Reconcile(taken, used)
+	}
+	return resp, nil
+}
@@ -0,0 +1,149 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+	"github.com/wintermonth2298/agentus/llms/ratelimit"
+)
+
+// tpm refills 100 tokens every second, 10 tokens take 100ms.
+const tpm = 6000
+
+func wait(t *testing.T, l *ratelimit.Limiter, tokens int) int {
+	t.Helper()
+
+	taken, err := l.Wait(context.Background(), tokens)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	return taken
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := ratelimit.NewLimiter(0, 0)
+	for range 1000 {
+		if taken := wait(t, l, 1_000_000); taken != 1_000_000 {
+			t.Fatalf("taken = %d, want all tokens", taken)
+		}
+	}
+}
+
+func TestLimiterWaitsForTokens(t *testing.T) {
+	l := ratelimit.NewLimiter(0, tpm)
+	wait(t, l, tpm)
+
+	start := time.Now()
+	wait(t, l, 10)
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("waited %v for an empty bucket, want about 100ms", elapsed)
+	}
+}
+
+func TestLimiterWaitsForRequests(t *testing.T) {
+	// 600 requests a minute refill one every 100ms
+	l := ratelimit.NewLimiter(600, 0)
+	for range 600 {
+		wait(t, l, 0)
+	}
+
+	start := time.Now()
+	wait(t, l, 0)
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("waited %v with no requests left, want about 100ms", elapsed)
+	}
+}
+
+func TestLimiterCapsLargeRequests(t *testing.T) {
+	l := ratelimit.NewLimiter(0, tpm)
+	if taken := wait(t, l, 10*tpm); taken != tpm {
+		t.Errorf("taken = %d, want the whole budget %d", taken, tpm)
+	}
+}
+
+func TestLimiterReconcile(t *testing.T) {
+	l := ratelimit.NewLimiter(0, tpm)
+	taken := wait(t, l, tpm)
+	l.Reconcile(taken, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, tpm-200); err != nil {
+		t.Errorf("wait after returning unused tokens: %v", err)
+	}
+}
+
+func TestLimiterWaitCancelled(t *testing.T) {
+	l := ratelimit.NewLimiter(0, tpm)
+	wait(t, l, tpm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.Wait(ctx, tpm); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled wait returned after %v", elapsed)
+	}
+}
+
+func TestLimiterQueuedWaitCancelled(t *testing.T) {
+	l := ratelimit.NewLimiter(0, tpm)
+	wait(t, l, tpm)
+
+	// the first waiter holds the turn for a minute
+	first, stopFirst := context.WithCancel(context.Background())
+	defer stopFirst()
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := l.Wait(first, tpm)
+		firstDone <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued waiter: err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	stopFirst()
+	if err := <-firstDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("first waiter: err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLLMReconcilesUsage(t *testing.T) {
+	reply := aiagenttest.Reply("hello")
+	reply.Response = reply.Response.WithUsage(aiagent.Usage{TotalTokens: 10})
+	llm := aiagenttest.NewFakeLLM(t,
+		reply,
+		aiagenttest.Fail(errors.New("boom")),
+		aiagenttest.Reply("again"),
+	)
+	l := ratelimit.NewLimiter(0, tpm)
+	// every prompt is estimated at half the budget
+	limited := ratelimit.New(llm, l, ratelimit.WithTokenCounter(func(aiagent.Message) int { return tpm / 2 }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	history := []aiagent.Message{aiagent.NewUserMessage("hi")}
+
+	// only the reported usage is kept and nothing for the failed call,
+	// so none of the calls has to wait for a refill
+	if _, err := limited.Call(ctx, history); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if _, err := limited.Call(ctx, history); err == nil {
+		t.Fatal("second call succeeded, want the llm error")
+	}
+	if _, err := limited.Call(ctx, history); err != nil {
+		t.Fatalf("third call: %v", err)
+	}
+	llm.AssertDone()
+}
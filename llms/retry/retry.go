@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+)
+
+const (
+	defaultMaxAttempts  = 4
+	defaultInitialDelay = 500 * time.Millisecond
+	defaultMaxDelay     = 30 * time.Second
+	defaultMaxElapsed   = 2 * time.Minute
+
+	// maxShift keeps the exponential delay from overflowing.
+	maxShift = 32
+)
+
+// LLM retries failed calls of the wrapped LLM with exponential backoff and
+// full jitter. By default only transient *aiagent.LLMError failures are
+// retried and a provider's RetryAfter is waited out before the next attempt.
+type LLM struct {
+	llm aiagent.LLM
+
+	maxAttempts  int
+	maxElapsed   time.Duration
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	retryable    func(err error) bool
+	onRetry      []func(ctx context.Context, attempt int, delay time.Duration, err error)
+}
+
+type Option func(*LLM)
+
+func New(llm aiagent.LLM, opts ...Option) *LLM {
+	l := &LLM{
+		llm:          llm,
+		maxAttempts:  defaultMaxAttempts,
+		maxElapsed:   defaultMaxElapsed,
+		initialDelay: defaultInitialDelay,
+		maxDelay:     defaultMaxDelay,
+		retryable:    isTransient,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithMaxAttempts limits the number of calls, the first one included.
+func WithMaxAttempts(n int) Option {
+	return func(l *LLM) {
+		l.maxAttempts = n
+	}
+}
+
+// WithMaxElapsed stops retrying when the next attempt would start later than
+// d after the first one. Zero removes the limit.
+func WithMaxElapsed(d time.Duration) Option {
+	return func(l *LLM) {
+		l.maxElapsed = d
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the cap for the
+// exponentially growing delays.
+func WithBackoff(initial time.Duration, maxDelay time.Duration) Option {
+	return func(l *LLM) {
+		l.initialDelay = initial
+		l.maxDelay = maxDelay
+	}
+}
+
+// WithRetryable replaces the decision which errors are worth retrying.
+func WithRetryable(f func(err error) bool) Option {
+	return func(l *LLM) {
+		l.retryable = f
+	}
+}
+
+// WithOnRetry registers f to be called before every retry, e.g. for logging.
+func WithOnRetry(f func(ctx context.Context, attempt int, delay time.Duration, err error)) Option {
+	return func(l *LLM) {
+		l.onRetry = append(l.onRetry, f)
+	}
+}
+
+func (l *LLM) Call(ctx context.Context, history []aiagent.Message) (aiagent.Message, error) {
+	return l.do(ctx, func(ctx context.Context) (aiagent.Message, bool, error) {
+		resp, err := l.llm.Call(ctx, history)
+		return resp, true, err
+	})
+}
+
+// Stream retries only failures that happen before the first delta,
+// so callers never see a response twice.
+func (l *LLM) Stream(
+	ctx context.Context,
+	history []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	return l.do(ctx, func(ctx context.Context) (aiagent.Message, bool, error) {
+		var emitted bool
+		resp, err := aiagent.StreamCall(ctx, l.llm, history, func(d aiagent.Delta) {
+			emitted = true
+			onDelta(d)
+		})
+		return resp, !emitted, err
+	})
+}
+
+func (l *LLM) RegisterTool(tool aiagent.Tool) {
+	l.llm.RegisterTool(tool)
+}
+
+func (l *LLM) ModelName() string {
+	return aiagent.ModelName(l.llm)
+}
+
+// do runs call until it succeeds or the error must not be retried.
+// call reports whether its failure may be retried at all.
+func (l *LLM) do(
+	ctx context.Context,
+	call func(ctx context.Context) (aiagent.Message, bool, error),
+) (aiagent.Message, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, retryable, err := call(ctx)
+		if err == nil {
+			return resp, nil
+		}
+		if !retryable || !l.retryable(err) || ctx.Err() != nil || attempt >= l.maxAttempts {
+			return aiagent.Message{}, err
+		}
+
+		delay := l.delay(attempt, err)
+		if l.maxElapsed > 0 && time.Since(start)+delay > l.maxElapsed {
+			return aiagent.Message{}, fmt.Errorf("retry budget exhausted after %d attempts: %w", attempt, err)
+		}
+		for _, f := range l.onRetry {
+			f(ctx, attempt, delay, err)
+		}
+
+		if errSleep := sleep(ctx, delay); errSleep != nil {
+			return aiagent.Message{}, err
+		}
+	}
+}
+
+// delay is a full jitter backoff for the given attempt, but never shorter
+// than the delay the provider asked for.
+func (l *LLM) delay(attempt int, err error) time.Duration {
+	backoff := l.maxDelay
+	if shift := attempt - 1; shift < maxShift && l.initialDelay<<shift < l.maxDelay {
+		backoff = l.initialDelay << shift
+	}
+	d := rand.N(backoff + 1) //nolint:gosec // jitter does not need a secure source
+
+	if after := retryAfter(err); after > d {
+		return after
+	}
+	return d
+}
+
+func isTransient(err error) bool {
+	return aiagent.ClassifyLLMError(err).Transient()
+}
+
+func retryAfter(err error) time.Duration {
+	var llmErr *aiagent.LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.RetryAfter
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
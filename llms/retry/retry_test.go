@@ -0,0 +1,193 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wintermonth2298/agentus/aiagent"
+	"github.com/wintermonth2298/agentus/aiagent/aiagenttest"
+	"github.com/wintermonth2298/agentus/llms/retry"
+)
+
+func llmError(class aiagent.LLMErrorClass, retryAfter time.Duration) error {
+	return &aiagent.LLMError{Class: class, RetryAfter: retryAfter, Err: errors.New(class.String())}
+}
+
+func history() []aiagent.Message {
+	return []aiagent.Message{aiagent.NewUserMessage("hi")}
+}
+
+// recordDelays collects the delays passed to WithOnRetry.
+func recordDelays(delays *[]time.Duration) retry.Option {
+	return retry.WithOnRetry(func(_ context.Context, _ int, delay time.Duration, _ error) {
+		*delays = append(*delays, delay)
+	})
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.Fail(llmError(aiagent.LLMErrorRateLimit, 0)),
+		aiagenttest.Fail(llmError(aiagent.LLMErrorServer, 0)),
+		aiagenttest.Fail(llmError(aiagent.LLMErrorNetwork, 0)),
+		aiagenttest.Reply("hello"),
+	)
+	r := retry.New(llm, retry.WithBackoff(time.Millisecond, time.Millisecond))
+
+	resp, err := r.Call(context.Background(), history())
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if resp.MustText() != "hello" {
+		t.Errorf("text = %q, want %q", resp.MustText(), "hello")
+	}
+	llm.AssertDone()
+}
+
+func TestRetryPermanentErrors(t *testing.T) {
+	for _, class := range []aiagent.LLMErrorClass{
+		aiagent.LLMErrorUnknown,
+		aiagent.LLMErrorQuota,
+		aiagent.LLMErrorAuth,
+		aiagent.LLMErrorInvalidRequest,
+		aiagent.LLMErrorContextLength,
+	} {
+		errCall := llmError(class, 0)
+		llm := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall))
+		r := retry.New(llm, retry.WithBackoff(time.Millisecond, time.Millisecond))
+
+		if _, err := r.Call(context.Background(), history()); !errors.Is(err, errCall) {
+			t.Errorf("%s: err = %v, want %v", class, err, errCall)
+		}
+		llm.AssertDone()
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorServer, 0)
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall), aiagenttest.Fail(errCall))
+	r := retry.New(llm, retry.WithMaxAttempts(2), retry.WithBackoff(time.Millisecond, time.Millisecond))
+
+	if _, err := r.Call(context.Background(), history()); !errors.Is(err, errCall) {
+		t.Fatalf("err = %v, want %v", err, errCall)
+	}
+	llm.AssertDone()
+}
+
+func TestRetryBackoff(t *testing.T) {
+	const attempts = 6
+	turns := make([]aiagenttest.Turn, 0, attempts)
+	for range attempts - 1 {
+		turns = append(turns, aiagenttest.Fail(llmError(aiagent.LLMErrorServer, 0)))
+	}
+	llm := aiagenttest.NewFakeLLM(t, append(turns, aiagenttest.Reply("hello"))...)
+
+	var delays []time.Duration
+	r := retry.New(llm,
+		retry.WithMaxAttempts(attempts),
+		retry.WithBackoff(time.Millisecond, 4*time.Millisecond),
+		recordDelays(&delays),
+	)
+	if _, err := r.Call(context.Background(), history()); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	// full jitter up to the doubling delay, capped by the maximum
+	caps := []time.Duration{1, 2, 4, 4, 4}
+	if len(delays) != len(caps) {
+		t.Fatalf("got %d retries, want %d", len(delays), len(caps))
+	}
+	for i, d := range delays {
+		if d < 0 || d > caps[i]*time.Millisecond {
+			t.Errorf("retry %d waited %v, want at most %v", i+1, d, caps[i]*time.Millisecond)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	const after = 20 * time.Millisecond
+	llm := aiagenttest.NewFakeLLM(t,
+		aiagenttest.Fail(llmError(aiagent.LLMErrorRateLimit, after)),
+		aiagenttest.Reply("hello"),
+	)
+
+	var delays []time.Duration
+	r := retry.New(llm, retry.WithBackoff(time.Millisecond, time.Millisecond), recordDelays(&delays))
+	start := time.Now()
+	if _, err := r.Call(context.Background(), history()); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	if len(delays) != 1 || delays[0] != after {
+		t.Errorf("delays = %v, want the provider's %v", delays, after)
+	}
+	if elapsed := time.Since(start); elapsed < after {
+		t.Errorf("retried after %v, want at least %v", elapsed, after)
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorRateLimit, time.Hour)
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall))
+	r := retry.New(llm, retry.WithMaxElapsed(time.Minute))
+
+	if _, err := r.Call(context.Background(), history()); !errors.Is(err, errCall) {
+		t.Fatalf("err = %v, want %v", err, errCall)
+	}
+	llm.AssertDone()
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorServer, time.Hour)
+	llm := aiagenttest.NewFakeLLM(t, aiagenttest.Fail(errCall))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := retry.New(llm, retry.WithMaxElapsed(0), retry.WithOnRetry(
+		func(context.Context, int, time.Duration, error) { cancel() }))
+
+	if _, err := r.Call(ctx, history()); !errors.Is(err, errCall) {
+		t.Fatalf("err = %v, want %v", err, errCall)
+	}
+	llm.AssertDone()
+}
+
+func TestRetryStream(t *testing.T) {
+	errCall := llmError(aiagent.LLMErrorServer, 0)
+	calls := 0
+	llm := streamFunc(func(onDelta func(aiagent.Delta)) (aiagent.Message, error) {
+		calls++
+		if calls == 1 {
+			return aiagent.Message{}, errCall
+		}
+		onDelta(aiagent.Delta{Text: "hel"})
+		return aiagent.Message{}, errCall
+	})
+	r := retry.New(llm, retry.WithBackoff(time.Millisecond, time.Millisecond))
+
+	var deltas []aiagent.Delta
+	_, err := r.Stream(context.Background(), history(), func(d aiagent.Delta) { deltas = append(deltas, d) })
+	if !errors.Is(err, errCall) {
+		t.Fatalf("err = %v, want %v", err, errCall)
+	}
+	// the failure before any delta is retried, the one after it is not
+	if calls != 2 || len(deltas) != 1 {
+		t.Errorf("got %d calls and %d deltas, want 2 and 1", calls, len(deltas))
+	}
+}
+
+type streamFunc func(onDelta func(aiagent.Delta)) (aiagent.Message, error)
+
+func (f streamFunc) Call(context.Context, []aiagent.Message) (aiagent.Message, error) {
+	return f(func(aiagent.Delta) {})
+}
+
+func (f streamFunc) Stream(
+	_ context.Context,
+	_ []aiagent.Message,
+	onDelta func(aiagent.Delta),
+) (aiagent.Message, error) {
+	return f(onDelta)
+}
+
+func (streamFunc) RegisterTool(aiagent.Tool) {}